@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/spf13/cobra"
+)
+
+func newAgentsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "agents",
+		Short: "Inspect Copilot agents",
+	}
+
+	cmd.AddCommand(
+		newAgentsListCommand(),
+		newAgentsRefreshCommand(),
+	)
+
+	return cmd
+}
+
+func newAgentsListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List all available agents",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agents, err := copilot.NewCopilot().FetchAgents(context.Background())
+			if err != nil {
+				return fmt.Errorf("failed to fetch agents: %v", err)
+			}
+
+			slugs := make([]string, 0, len(agents))
+			for slug := range agents {
+				slugs = append(slugs, slug)
+			}
+			sort.Strings(slugs)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SLUG\tNAME\tDESCRIPTION")
+			for _, slug := range slugs {
+				agent := agents[slug]
+				fmt.Fprintf(w, "%s\t%s\t%s\n", agent.Slug, agent.Name, agent.Description)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newAgentsRefreshCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "refresh",
+		Short: "Fetch the agent list again, ignoring the cache",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agents, err := copilot.NewCopilot().RefreshAgents(context.Background())
+			if err != nil {
+				return fmt.Errorf("failed to refresh agents: %v", err)
+			}
+
+			fmt.Printf("Successfully refreshed %d agents\n", len(agents))
+			return nil
+		},
+	}
+}
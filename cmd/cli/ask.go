@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/spf13/cobra"
+)
+
+func newAskCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ask [question]",
+		Short: "Ask Copilot a single question",
+		Example: `  lazycopilot ask "how do I undo the last commit?"
+  cat main.go | lazycopilot ask "what does this do"
+  lazycopilot ask --raw "write a bash script that counts lines" > count.sh`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			model, _ := cmd.Flags().GetString("model")
+			system, _ := cmd.Flags().GetString("system")
+			agent, _ := cmd.Flags().GetString("agent")
+			raw, _ := cmd.Flags().GetBool("raw")
+			sessionName, _ := cmd.Flags().GetString("session")
+
+			question := strings.Join(args, " ")
+			input, err := readPipedStdin()
+			if err != nil {
+				return fmt.Errorf("failed to read stdin: %v", err)
+			}
+			if input != "" {
+				question = strings.TrimSpace(question + "\n\n" + strings.ReplaceAll(config.STDIN_PROMPT, "{{input}}", input))
+			}
+			if question == "" {
+				return errors.New("a question is required, either as an argument or on stdin")
+			}
+
+			if raw {
+				if system == "" {
+					system = strings.TrimSpace(copilot.COPILOT_INSTRUCTIONS)
+				}
+				system += "\n" + config.RAW_OUTPUT_INSTRUCTIONS
+			}
+
+			client := copilot.NewCopilot()
+			session, err := openSession(client, sessionName)
+			if err != nil {
+				return err
+			}
+			model = sessionModel(session, model)
+
+			if tools, _ := cmd.Flags().GetBool("tools"); tools {
+				path, _ := os.Getwd()
+				if err := registerGitTools(client, path); err != nil {
+					return err
+				}
+			}
+
+			ctx := context.Background()
+			opts := &copilot.AskOptions{
+				Model:        model,
+				SystemPrompt: system,
+				Agent:        agent,
+			}
+
+			if raw {
+				// The whole response is needed to strip the code block around it
+				content, err := client.Ask(ctx, question, opts)
+				if content != "" {
+					fmt.Println(trimCodeBlock(content))
+				}
+				if err != nil {
+					return err
+				}
+			} else {
+				_, err := client.AskStream(ctx, question, opts, func(chunk string) {
+					fmt.Print(chunk)
+				})
+				fmt.Println()
+				if err != nil {
+					return err
+				}
+			}
+
+			return saveSession(client, session, model)
+		},
+	}
+	cmd.Flags().StringP("model", "m", "", "Model to ask (default is the configured default model)")
+	cmd.Flags().String("system", "", "System prompt (default is the Copilot instructions)")
+	cmd.Flags().StringP("agent", "a", "", "Route the question to an agent, see 'lazycopilot agents list'")
+	cmd.Flags().BoolP("raw", "r", false, "Print plain text without markdown decoration")
+	cmd.Flags().Bool("tools", false, "Let the model read the git history and files of the current repository")
+	cmd.Flags().String("session", "", "Continue the named conversation session and save the exchange to it")
+	return cmd
+}
+
+// readPipedStdin returns the content piped into the command, or nothing when
+// stdin is a terminal.
+func readPipedStdin() (string, error) {
+	stat, err := os.Stdin.Stat()
+	if err != nil || stat.Mode()&os.ModeCharDevice != 0 {
+		return "", nil
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// trimCodeBlock removes a code block wrapped around the whole content,
+// including its language tag.
+func trimCodeBlock(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") || !strings.HasSuffix(content, "```") {
+		return content
+	}
+
+	content = strings.TrimSuffix(content, "```")
+	if i := strings.Index(content, "\n"); i != -1 {
+		content = content[i+1:]
+	} else {
+		content = strings.TrimPrefix(content, "```")
+	}
+	return strings.Trim(content, "\n")
+}
@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/mr687/lazycopilot/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// listMarker matches the bullets and numbers models put in front of list
+// items despite being asked not to.
+var listMarker = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s+`)
+
+func newBranchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "branch",
+		Short: "Work with branches",
+	}
+
+	cmd.AddCommand(
+		newBranchSuggestCommand(),
+	)
+
+	return cmd
+}
+
+func newBranchSuggestCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "suggest [task description]",
+		Short: "Suggest branch names from the current changes or a task description",
+		Long: `Suggest branch names following the type/TICKET-short-slug convention, from a
+description of the task or, without one, from the staged and unstaged changes.
+
+Names must match the branch_pattern of the settings and be accepted by
+git check-ref-format. With --switch the chosen name is created and checked
+out, carrying the uncommitted changes over.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, _ := cmd.Flags().GetString("path")
+			if path == "" {
+				path, _ = os.Getwd()
+			}
+			count, _ := cmd.Flags().GetInt("count")
+			if count < 1 {
+				return errors.New("--count must be at least 1")
+			}
+			ticket, _ := cmd.Flags().GetString("ticket")
+			switchBranch, _ := cmd.Flags().GetBool("switch")
+			model, _ := cmd.Flags().GetString("model")
+			pattern, _ := cmd.Flags().GetString("pattern")
+			if pattern == "" {
+				pattern = config.LoadSettings().GetBranchPattern()
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid branch pattern: %w", err)
+			}
+
+			ticketInstructions := "the ticket id followed by a hyphen when the task mentions one, e.g. PROJ-123-,"
+			if ticket != "" {
+				ticketInstructions = "the ticket id " + ticket + " followed by a hyphen"
+			}
+			instructions := strings.NewReplacer(
+				"{{count}}", strconv.Itoa(count),
+				"{{ticket}}", ticketInstructions,
+				"{{pattern}}", pattern,
+			)
+
+			ctx := context.Background()
+			client := copilot.NewCopilot()
+			var prompt string
+			if task := strings.TrimSpace(strings.Join(args, " ")); task != "" {
+				prompt = strings.ReplaceAll(instructions.Replace(config.BRANCH_TASK_PROMPT), "{{task}}", task)
+			} else {
+				diff := workingTreeDiff(path)
+				if diff == "" {
+					return errors.New("no changes to tracked files detected, describe the task instead")
+				}
+				if prompt, err = buildDiffPrompt(ctx, client, diff, diffPromptOptions{
+					Template: instructions.Replace(config.BRANCH_DIFF_PROMPT),
+					Model:    model,
+				}); err != nil {
+					return err
+				}
+			}
+
+			content, err := client.NewConversation().Ask(ctx, prompt, &copilot.AskOptions{Model: model})
+			if err != nil {
+				return fmt.Errorf("failed to suggest branch names: %w", err)
+			}
+
+			names := validBranchNames(path, parseBranchNames(content), re)
+			if len(names) == 0 {
+				return fmt.Errorf("none of the suggested names matches %s", pattern)
+			}
+			if len(names) > count {
+				names = names[:count]
+			}
+
+			if !switchBranch {
+				for _, name := range names {
+					fmt.Println(name)
+				}
+				return nil
+			}
+
+			name := names[0]
+			if len(names) > 1 && isTerminal(os.Stdin) {
+				printCandidates(names)
+				if name, err = chooseCandidate("branch name", names); err != nil {
+					return err
+				}
+			}
+			if err := utils.SwitchNewBranch(path, name); err != nil {
+				return fmt.Errorf("failed to create the branch: %w", err)
+			}
+			fmt.Printf("Switched to a new branch '%s'\n", name)
+			return nil
+		},
+	}
+	cmd.Flags().StringP("path", "p", "", "Path to the repository (default is current directory)")
+	cmd.Flags().IntP("count", "n", 3, "Number of names to suggest")
+	cmd.Flags().StringP("ticket", "t", "", "Ticket id to put in the names, e.g. PROJ-123")
+	cmd.Flags().BoolP("switch", "s", false, "Create the chosen branch and switch to it")
+	cmd.Flags().String("pattern", "", "Regular expression the names must match (default is the branch_pattern setting)")
+	cmd.Flags().StringP("model", "m", "", "Model used to suggest the names (default is the configured default model)")
+	return cmd
+}
+
+// workingTreeDiff returns the staged and unstaged changes together.
+func workingTreeDiff(path string) string {
+	diffs := make([]string, 0, 2)
+	for _, staged := range []bool{true, false} {
+		if diff := utils.GetDiff(path, staged); diff != "" {
+			diffs = append(diffs, diff)
+		}
+	}
+	return strings.Join(diffs, "\n")
+}
+
+// parseBranchNames returns the names of the response, one per line.
+func parseBranchNames(content string) []string {
+	names := make([]string, 0)
+	for _, line := range strings.Split(trimCodeBlock(content), "\n") {
+		name := listMarker.ReplaceAllString(strings.TrimSpace(line), "")
+		name = strings.Trim(name, "`'\"")
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// validBranchNames keeps the names that match the pattern, are valid for git
+// and don't exist yet, reporting the others on stderr.
+func validBranchNames(path string, names []string, pattern *regexp.Regexp) []string {
+	valid := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		switch {
+		case !pattern.MatchString(name):
+			fmt.Fprintf(os.Stderr, "Skipped '%s': it doesn't match the branch pattern\n", name)
+		case utils.CheckBranchName(path, name) != nil:
+			fmt.Fprintf(os.Stderr, "Skipped '%s': it isn't a valid branch name\n", name)
+		case utils.BranchExists(path, name):
+			fmt.Fprintf(os.Stderr, "Skipped '%s': the branch already exists\n", name)
+		default:
+			valid = append(valid, name)
+		}
+	}
+	return valid
+}
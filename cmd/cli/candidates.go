@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// candidateTemperature makes alternative messages differ from each other,
+// the default temperature yields near identical ones.
+const candidateTemperature = 0.8
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+func printCandidates(candidates []string) {
+	for i, candidate := range candidates {
+		fmt.Printf("[%d] %s\n\n", i+1, strings.ReplaceAll(candidate, "\n", "\n    "))
+	}
+}
+
+func printCandidatesJSON(candidates []string) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(candidates)
+}
+
+// chooseCandidate asks which candidate, e.g. which message, to use until a
+// valid number is entered. Entering nothing picks the first one.
+func chooseCandidate(what string, candidates []string) (string, error) {
+	in := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("Choose a %s [1-%d] (default 1): ", what, len(candidates))
+		line, err := in.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" && err != nil {
+			return "", fmt.Errorf("no %s chosen: %w", what, err)
+		}
+		if line == "" {
+			return candidates[0], nil
+		}
+
+		choice, convErr := strconv.Atoi(line)
+		if convErr == nil && choice >= 1 && choice <= len(candidates) {
+			return candidates[choice-1], nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("no %s chosen: %w", what, err)
+		}
+		fmt.Printf("Please enter a number between 1 and %d\n", len(candidates))
+	}
+}
@@ -0,0 +1,338 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mr687/lazycopilot/pkg/commit"
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/mr687/lazycopilot/pkg/tokenizer"
+	"github.com/mr687/lazycopilot/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	audienceUsers      = "users"
+	audienceDevelopers = "developers"
+)
+
+// versionHeading matches the heading semantic-release writes for a release,
+// e.g. "# [1.1.0](...) (2025-02-13)" or "# 1.0.0 (2025-02-08)".
+var versionHeading = regexp.MustCompile(`^#{1,2} \[?v?\d`)
+
+// remoteURLPattern matches the host and repository of a remote URL, over
+// ssh as in git@github.com:owner/repo.git or over http.
+var remoteURLPattern = regexp.MustCompile(`^(?:[a-z+]+://)?(?:[^@/]+@)?([^:/]+)(?::\d+)?[:/](.+?)(?:\.git)?/?$`)
+
+func newChangelogCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "changelog <from>..<to>",
+		Short: "Generate release notes from the conventional commits of a range",
+		Long: `Generate release notes from the conventional commits between two tags,
+e.g. v1.0.0..v1.1.0, grouped into features, fixes and breaking changes.
+
+The notes are written in the format semantic-release uses for CHANGELOG.md.
+With --write they are prepended to the changelog as a new section. The version
+defaults to the <to> tag, use --version when it isn't tagged yet.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, _ := cmd.Flags().GetString("path")
+			if path == "" {
+				path, _ = os.Getwd()
+			}
+			audience, _ := cmd.Flags().GetString("audience")
+			if audience != audienceUsers && audience != audienceDevelopers {
+				return fmt.Errorf("invalid audience '%s', use %s or %s", audience, audienceUsers, audienceDevelopers)
+			}
+			version, _ := cmd.Flags().GetString("version")
+			// The tag gets its prefix from the other tags
+			version = strings.TrimPrefix(version, "v")
+			write, _ := cmd.Flags().GetBool("write")
+			file, _ := cmd.Flags().GetString("file")
+			model, _ := cmd.Flags().GetString("model")
+
+			from, to, ok := splitRange(args[0])
+			if !ok {
+				return fmt.Errorf("invalid range '%s', use <from>..<to>", args[0])
+			}
+			if to == "" {
+				to = "HEAD"
+			}
+
+			// The tag the release will have, which may not exist yet
+			tag := ""
+			switch {
+			case version != "":
+				tag = version
+				if strings.HasPrefix(from, "v") {
+					tag = "v" + version
+				}
+			case utils.IsTag(path, to):
+				tag = to
+				version = strings.TrimPrefix(to, "v")
+			default:
+				return fmt.Errorf("'%s' is not a tag, give the version of the release with --version", to)
+			}
+
+			revRange := to
+			if from != "" {
+				revRange = from + ".." + to
+			}
+			hashes, err := utils.ListCommits(path, revRange, "--no-merges")
+			if err != nil {
+				return fmt.Errorf("failed to list the commits of %s: %w", args[0], err)
+			}
+
+			commits := make([]commit.ConventionalCommit, 0, len(hashes))
+			for _, hash := range hashes {
+				message, err := utils.GetCommitMessage(path, hash)
+				if err != nil {
+					return err
+				}
+				commits = append(commits, commit.ParseConventionalCommit(hash, message))
+			}
+			groups := commit.GroupCommits(commits, audience == audienceDevelopers)
+			if len(groups) == 0 {
+				return fmt.Errorf("no changes worth noting for %s in %s", audience, args[0])
+			}
+
+			// Refuse a release the changelog already has before writing notes
+			current := ""
+			if write {
+				if !filepath.IsAbs(file) {
+					root, err := utils.GetRepoRoot(path)
+					if err != nil {
+						return err
+					}
+					file = filepath.Join(root, file)
+				}
+				if current, err = readChangelog(file, version); err != nil {
+					return err
+				}
+			}
+
+			repoURL := repoWebURL(utils.GetRemoteURL(path, "origin"))
+			date, err := utils.GetCommitDate(path, to)
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			client := copilot.NewCopilot()
+			template := strings.ReplaceAll(config.CHANGELOG_PROMPT, "{{audience}}", audienceInstructions(audience))
+			list, err := fitChangelogGroups(ctx, client, model, template, groups, repoURL)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "Writing release notes for %d commits...\n", len(commits))
+			prompt := strings.ReplaceAll(template, "{{commits}}", list)
+			content, err := client.NewConversation().Ask(ctx, prompt, &copilot.AskOptions{Model: model})
+			if err != nil {
+				return fmt.Errorf("failed to generate the release notes: %w", err)
+			}
+
+			entry := changelogHeading(version, from, tag, date, repoURL) + "\n\n\n" + formatChangelogSections(trimCodeBlock(content)) + "\n"
+			if !write {
+				fmt.Print(entry)
+				return nil
+			}
+
+			if err := os.WriteFile(file, []byte(prependChangelog(current, entry)), 0o644); err != nil {
+				return fmt.Errorf("failed to write the changelog: %w", err)
+			}
+			fmt.Printf("Added the release notes of %s to %s\n", version, file)
+			return nil
+		},
+	}
+	cmd.Flags().StringP("path", "p", "", "Path to the repository (default is current directory)")
+	cmd.Flags().StringP("audience", "a", audienceUsers, fmt.Sprintf("Readers of the notes: %s or %s", audienceUsers, audienceDevelopers))
+	cmd.Flags().String("version", "", "Version of the release (default is the <to> tag without the v prefix)")
+	cmd.Flags().BoolP("write", "w", false, "Prepend the notes to the changelog instead of printing them")
+	cmd.Flags().StringP("file", "f", "CHANGELOG.md", "Changelog written by --write, relative to the top level directory")
+	cmd.Flags().StringP("model", "m", "", "Model used to write the notes (default is the configured default model)")
+	return cmd
+}
+
+func audienceInstructions(audience string) string {
+	if audience == audienceDevelopers {
+		return config.CHANGELOG_DEVELOPERS_INSTRUCTIONS
+	}
+	return config.CHANGELOG_USERS_INSTRUCTIONS
+}
+
+// fitChangelogGroups renders the groups for the {{commits}} placeholder of
+// template within the prompt limit of the model. The bodies of the commits
+// are left out first, then the commits of the last sections.
+func fitChangelogGroups(ctx context.Context, client copilot.Copilot, model, template string, groups []commit.ChangelogGroup, repoURL string) (string, error) {
+	list := formatChangelogGroups(groups, repoURL, true)
+
+	modelConfig, err := client.GetModel(ctx, model)
+	if err != nil {
+		return "", err
+	}
+	maxPromptTokens := modelConfig.Capabilities.Limits.MaxPromptTokens
+	if maxPromptTokens <= 0 {
+		return list, nil
+	}
+	tok, err := tokenizer.New(modelConfig.Capabilities.Tokenizer)
+	if err != nil {
+		return "", err
+	}
+
+	budget := maxPromptTokens - tok.Count(copilot.COPILOT_INSTRUCTIONS) - promptOverheadTokens - tok.Count(strings.ReplaceAll(template, "{{commits}}", ""))
+	if tok.Count(list) <= budget {
+		return list, nil
+	}
+	list = formatChangelogGroups(groups, repoURL, false)
+	if tok.Count(list) <= budget {
+		fmt.Fprintf(os.Stderr, "Warning: The commits exceed the prompt limit of %s, left out their bodies\n", modelConfig.ID)
+		return list, nil
+	}
+
+	// Drop commits from the end, where the least important sections are,
+	// keeping the breaking changes that follow them for last
+	kept := slices.Clone(groups)
+	omitted := 0
+	for tok.Count(list) > budget && len(kept) > 0 {
+		i := len(kept) - 1
+		for j := i; j >= 0; j-- {
+			if kept[j].Title != commit.BreakingChangesTitle {
+				i = j
+				break
+			}
+		}
+		kept[i].Commits = kept[i].Commits[:len(kept[i].Commits)-1]
+		if len(kept[i].Commits) == 0 {
+			kept = slices.Delete(kept, i, i+1)
+		}
+		omitted++
+		list = formatChangelogGroups(kept, repoURL, false)
+	}
+	if len(kept) == 0 {
+		return "", fmt.Errorf("the prompt leaves no room for the commits within the prompt limit of %s (%d tokens)", modelConfig.ID, maxPromptTokens)
+	}
+	fmt.Fprintf(os.Stderr, "Warning: The commits exceed the prompt limit of %s, left out their bodies and %d commits\n", modelConfig.ID, omitted)
+	return list, nil
+}
+
+// formatChangelogGroups renders the groups as the sections of a changelog,
+// optionally with the bodies of the commits indented below their entries
+// for context.
+func formatChangelogGroups(groups []commit.ChangelogGroup, repoURL string, bodies bool) string {
+	sections := make([]string, 0, len(groups))
+	for _, group := range groups {
+		lines := []string{"### " + group.Title, ""}
+		for _, c := range group.Commits {
+			entry := "* "
+			if c.Scope != "" {
+				entry += "**" + c.Scope + ":** "
+			}
+			description := c.Description
+			if group.Title == commit.BreakingChangesTitle && c.BreakingNote != "" {
+				description = c.BreakingNote
+			}
+			lines = append(lines, entry+description+" "+commitReference(c.Hash, repoURL))
+			if bodies && c.Body != "" {
+				lines = append(lines, "  "+strings.ReplaceAll(c.Body, "\n", "\n  "))
+			}
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+func commitReference(hash, repoURL string) string {
+	if repoURL == "" {
+		return "(" + hash[:7] + ")"
+	}
+	return fmt.Sprintf("([%s](%s/commit/%s))", hash[:7], repoURL, hash)
+}
+
+// changelogHeading returns the heading semantic-release writes for a release.
+// Patch releases get a smaller heading and the first release has no link to
+// compare it with.
+func changelogHeading(version, from, tag, date, repoURL string) string {
+	level := "#"
+	if isPatchVersion(version) {
+		level = "##"
+	}
+	if from == "" || repoURL == "" {
+		return fmt.Sprintf("%s %s (%s)", level, version, date)
+	}
+	return fmt.Sprintf("%s [%s](%s/compare/%s...%s) (%s)", level, version, repoURL, from, tag, date)
+}
+
+func isPatchVersion(version string) bool {
+	core, _, _ := strings.Cut(version, "-")
+	parts := strings.Split(core, ".")
+	return len(parts) == 3 && strings.TrimLeft(parts[2], "0") != ""
+}
+
+// formatChangelogSections separates the sections the model wrote by two
+// blank lines, as semantic-release does.
+func formatChangelogSections(content string) string {
+	sections := make([]string, 0)
+	current := make([]string, 0)
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "### ") && len(current) > 0 {
+			sections = append(sections, strings.TrimSpace(strings.Join(current, "\n")))
+			current = current[:0]
+		}
+		current = append(current, line)
+	}
+	sections = append(sections, strings.TrimSpace(strings.Join(current, "\n")))
+	return strings.Join(sections, "\n\n\n")
+}
+
+// readChangelog returns the content of the changelog, which must not have
+// the release notes of version yet.
+func readChangelog(file, version string) (string, error) {
+	current, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	existing := regexp.MustCompile(`(?m)^#{1,2} \[?` + regexp.QuoteMeta(version) + `[\] ]`)
+	if existing.Match(current) {
+		return "", fmt.Errorf("%s already has the release notes of %s", file, version)
+	}
+	return string(current), nil
+}
+
+// prependChangelog adds entry as the newest release of the changelog, below
+// its title if it has one.
+func prependChangelog(current, entry string) string {
+	title := ""
+	rest := current
+	if first, remainder, _ := strings.Cut(rest, "\n"); strings.HasPrefix(first, "# ") && !versionHeading.MatchString(first) {
+		title = first + "\n\n"
+		rest = strings.TrimLeft(remainder, "\n")
+	}
+
+	content := title + entry
+	if rest != "" {
+		content += "\n" + rest
+	}
+	return content
+}
+
+// repoWebURL returns the web address of a repository from the URL of its
+// remote, or nothing when it isn't recognized.
+func repoWebURL(remote string) string {
+	match := remoteURLPattern.FindStringSubmatch(remote)
+	if match == nil {
+		return ""
+	}
+	return "https://" + match[1] + "/" + match[2]
+}
@@ -0,0 +1,307 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/mr687/lazycopilot/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+const chatHelp = `Commands:
+  /model [id]       Show or switch the model
+  /system [prompt]  Show or set the system prompt, "/system reset" restores the default
+  /agent [slug]     Show or switch the agent, "/agent copilot" talks to the model directly
+  /clear            Forget the conversation so far
+  /save [name]      Save the conversation as a session
+  /diff [path]      Attach the staged diff to your next message
+  /help             Show this help
+  /exit             Quit (or press Ctrl-D)
+
+End a line with \ to continue on the next one, or wrap multiple lines in """.
+Press Ctrl-C to cancel a response.`
+
+func newChatCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "chat",
+		Short: "Chat with Copilot interactively",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			model, _ := cmd.Flags().GetString("model")
+			system, _ := cmd.Flags().GetString("system")
+			agent, _ := cmd.Flags().GetString("agent")
+			sessionName, _ := cmd.Flags().GetString("session")
+
+			client := copilot.NewCopilot()
+			session, err := openSession(client, sessionName)
+			if err != nil {
+				return err
+			}
+
+			repl := &chatREPL{
+				client:  client,
+				session: session,
+				model:   sessionModel(session, model),
+				system:  system,
+				agent:   agent,
+				in:      bufio.NewReader(os.Stdin),
+			}
+			return repl.run()
+		},
+	}
+	cmd.Flags().StringP("model", "m", "", "Model to chat with (default is the configured default model)")
+	cmd.Flags().String("system", "", "System prompt (default is the Copilot instructions)")
+	cmd.Flags().StringP("agent", "a", "", "Chat with an agent, see 'lazycopilot agents list'")
+	cmd.Flags().String("session", "", "Continue the named conversation session and save the exchange to it")
+	return cmd
+}
+
+type chatREPL struct {
+	client  copilot.Copilot
+	session *copilot.Session
+	model   string
+	system  string
+	agent   string
+	// attachment is prepended to the next message, e.g. the staged diff
+	attachment string
+	in         *bufio.Reader
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func (r *chatREPL) run() error {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go r.handleInterrupts(interrupts)
+
+	// Keep the token valid while waiting for input, so that replies after
+	// a long pause don't start with a token exchange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.client.RefreshInBackground(ctx)
+
+	fmt.Println("Chatting with Copilot. Type /help for commands.")
+	for {
+		input, err := r.readInput()
+		if errors.Is(err, io.EOF) {
+			fmt.Println()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+
+		if strings.HasPrefix(input, "/") {
+			quit, err := r.runCommand(input)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			if quit {
+				return nil
+			}
+			continue
+		}
+
+		if err := r.ask(input); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	}
+}
+
+// handleInterrupts cancels the request in flight on Ctrl-C instead of
+// quitting the chat.
+func (r *chatREPL) handleInterrupts(interrupts <-chan os.Signal) {
+	for range interrupts {
+		r.mu.Lock()
+		if r.cancel != nil {
+			r.cancel()
+		} else {
+			fmt.Print("\n(Type /exit or press Ctrl-D to quit)\n> ")
+		}
+		r.mu.Unlock()
+	}
+}
+
+// readInput reads one message. Lines ending with a backslash continue on the
+// next line and lines between """ markers are read as a single message.
+func (r *chatREPL) readInput() (string, error) {
+	fmt.Print("> ")
+
+	lines := make([]string, 0)
+	block := false
+	for {
+		line, err := r.in.ReadString('\n')
+		if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.TrimSpace(line) == `"""`:
+			if block {
+				return strings.Join(lines, "\n"), nil
+			}
+			block = true
+		case block:
+			lines = append(lines, line)
+		case strings.HasSuffix(line, `\`):
+			lines = append(lines, strings.TrimSuffix(line, `\`))
+		default:
+			lines = append(lines, line)
+			return strings.Join(lines, "\n"), nil
+		}
+		fmt.Print(". ")
+	}
+}
+
+func (r *chatREPL) ask(prompt string) error {
+	// The attachment stays for the next message until it was answered
+	if r.attachment != "" {
+		prompt = r.attachment + "\n\n" + prompt
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.mu.Lock()
+	r.cancel = cancel
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.cancel = nil
+		r.mu.Unlock()
+		cancel()
+	}()
+
+	_, err := r.client.AskStream(ctx, prompt, &copilot.AskOptions{
+		Model:        r.model,
+		SystemPrompt: r.system,
+		Agent:        r.agent,
+	}, func(chunk string) {
+		fmt.Print(chunk)
+	})
+	fmt.Println()
+	if errors.Is(ctx.Err(), context.Canceled) {
+		fmt.Println("(Cancelled)")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r.attachment = ""
+
+	return saveSession(r.client, r.session, r.model)
+}
+
+func (r *chatREPL) runCommand(input string) (bool, error) {
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "/exit", "/quit":
+		return true, nil
+	case "/help":
+		fmt.Println(chatHelp)
+	case "/model":
+		if arg == "" {
+			model, err := r.client.GetModel(context.Background(), r.model)
+			if err != nil {
+				return false, err
+			}
+			fmt.Println(model.ID)
+			return false, nil
+		}
+		if _, err := r.client.GetModel(context.Background(), arg); err != nil {
+			return false, err
+		}
+		r.model = arg
+		fmt.Printf("Switched to model '%s'\n", arg)
+	case "/system":
+		switch arg {
+		case "":
+			if r.system == "" {
+				fmt.Println(strings.TrimSpace(copilot.COPILOT_INSTRUCTIONS))
+			} else {
+				fmt.Println(r.system)
+			}
+		case "reset":
+			r.system = ""
+			fmt.Println("System prompt restored to the default")
+		default:
+			r.system = arg
+			fmt.Println("System prompt updated")
+		}
+	case "/agent":
+		if arg == "" {
+			if r.agent == "" {
+				fmt.Println(copilot.DefaultAgent)
+			} else {
+				fmt.Println(r.agent)
+			}
+			return false, nil
+		}
+		agents, err := r.client.FetchAgents(context.Background())
+		if err != nil {
+			return false, err
+		}
+		agent, ok := agents[arg]
+		if !ok {
+			return false, fmt.Errorf("agent '%s' not found, see 'lazycopilot agents list'", arg)
+		}
+		r.agent = arg
+		fmt.Printf("Switched to agent '%s': %s\n", agent.Slug, agent.Description)
+	case "/clear":
+		r.client.SetHistory(nil)
+		r.attachment = ""
+		// The session would bring the cleared messages back otherwise
+		if err := saveSession(r.client, r.session, r.model); err != nil {
+			return false, err
+		}
+		fmt.Println("Conversation cleared")
+	case "/save":
+		if arg != "" && (r.session == nil || r.session.Name != arg) {
+			exists, err := copilot.SessionExists(arg)
+			if err != nil {
+				return false, err
+			}
+			if exists {
+				return false, fmt.Errorf("session '%s' already exists, pick another name or delete it with 'lazycopilot sessions delete %s'", arg, arg)
+			}
+			r.session = copilot.NewSession(arg)
+		}
+		if r.session == nil {
+			return false, errors.New("a session name is required, e.g. /save my-session")
+		}
+		if err := saveSession(r.client, r.session, r.model); err != nil {
+			return false, err
+		}
+		fmt.Printf("Saved session '%s'\n", r.session.Name)
+	case "/diff":
+		path := arg
+		if path == "" {
+			path, _ = os.Getwd()
+		}
+		diff := utils.GetDiff(path, true)
+		if diff == "" {
+			return false, errors.New("no staged changes detected")
+		}
+		r.attachment = strings.ReplaceAll(config.STAGED_DIFF_PROMPT, "{{diff}}", diff)
+		fmt.Printf("Attached the staged diff (%d lines) to your next message\n", strings.Count(diff, "\n")+1)
+	default:
+		return false, fmt.Errorf("unknown command %s, type /help for commands", name)
+	}
+	return false, nil
+}
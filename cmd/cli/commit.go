@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -31,6 +32,8 @@ func newCommitCommand() *cobra.Command {
 
 	cmd.AddCommand(
 		newCommitGenCommand(),
+		newCommitRewordCommand(),
+		newCommitSquashMsgCommand(),
 		newCommitStyleListCommand(),
 		newCommitStyleAddCommand(),
 		newCommitStyleRemoveCommand(),
@@ -51,6 +54,11 @@ func newCommitGenCommand() *cobra.Command {
 	cmd.Flags().BoolP("title-only", "t", false, "Generate only the commit title")
 	cmd.Flags().StringP("style", "S", "normal", fmt.Sprintf("Style of the commit title: %s", strings.Join(commit.GetAvailableStyles(), ", ")))
 	cmd.Flags().BoolP("no-commit", "n", false, "Do not commit the generated content immediately")
+	cmd.Flags().StringP("model", "m", "", "Model used to generate the message (default is the configured default model)")
+	cmd.Flags().Bool("tools", false, "Let the model read the git history and files of the repository for context")
+	cmd.Flags().String("session", "", "Continue the named conversation session and save the exchange to it")
+	cmd.Flags().IntP("candidates", "c", 1, "Number of alternative messages to choose from, printed as JSON when not run interactively")
+	cmd.Flags().String("summarize", summarizeAuto, fmt.Sprintf("Summarize the diff in parts before writing the message: %s", strings.Join(summarizeModes, ", ")))
 	return cmd
 }
 
@@ -198,31 +206,87 @@ func commitRunner(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	commitPrompt := strings.ReplaceAll(config.COMMIT_PROMPT, "{{diff}}", diff)
+	summarize, _ := cmd.Flags().GetString("summarize")
+	if !isValidSummarizeMode(summarize) {
+		fmt.Printf("Error: Invalid summarize mode '%s'. Available modes: %s\n", summarize, strings.Join(summarizeModes, ", "))
+		os.Exit(1)
+	}
+
+	promptSuffix := ""
 	titleOnly, _ := cmd.Flags().GetBool("title-only")
 	if titleOnly {
-		commitPrompt += "\n\nGenerate only the commit title."
+		promptSuffix += "\n\nGenerate only the commit title."
 	}
 
 	if stylePrompt := commit.GetStylePrompt(commit.Style(style)); stylePrompt != "" {
-		commitPrompt += stylePrompt
+		promptSuffix += stylePrompt
 	}
 
-	copilot := copilot.NewCopilot()
-	content, err := copilot.Ask(ctx, commitPrompt, nil)
+	candidates, _ := cmd.Flags().GetInt("candidates")
+	if candidates < 1 {
+		fmt.Println("Error: --candidates must be at least 1.")
+		os.Exit(1)
+	}
+
+	noCommit, _ := cmd.Flags().GetBool("no-commit")
+	model, _ := cmd.Flags().GetString("model")
+	sessionName, _ := cmd.Flags().GetString("session")
+
+	client := copilot.NewCopilot()
+	session, err := openSession(client, sessionName)
 	if err != nil {
-		fmt.Printf("Error: Failed to generate commit message. Details: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+	model = sessionModel(session, model)
+	askOpts := &copilot.AskOptions{Model: model}
 
-	// Remove code block wrappers from the generated content
-	content = strings.TrimPrefix(content, "```")
-	content = strings.TrimSuffix(content, "```")
+	if tools, _ := cmd.Flags().GetBool("tools"); tools {
+		if err := registerGitTools(client, path); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	// Remove extra newlines from the generated content
-	content = strings.Trim(content, "\n")
+	commitPrompt, err := buildDiffPrompt(ctx, client, diff, diffPromptOptions{
+		Template:          config.COMMIT_PROMPT,
+		SummariesTemplate: config.COMMIT_SUMMARIES_PROMPT,
+		Suffix:            promptSuffix,
+		Model:             model,
+		Summarize:         summarize,
+		SystemPrompt:      askOpts.SystemPrompt,
+		History:           client.History(),
+	})
+	if err != nil {
+		fmt.Printf("Error: Failed to prepare the prompt. Details: %v\n", err)
+		os.Exit(1)
+	}
+
+	var content string
+	if candidates > 1 {
+		content = chooseCommitCandidate(ctx, client, commitPrompt, model, candidates, noCommit)
+		if content == "" {
+			return
+		}
+	} else {
+		if noCommit {
+			// Print the message as it arrives since nothing else happens with it
+			content, err = client.AskStream(ctx, commitPrompt, askOpts, func(chunk string) {
+				fmt.Print(chunk)
+			})
+			fmt.Println()
+		} else {
+			content, err = client.Ask(ctx, commitPrompt, askOpts)
+		}
+		exitOnGenerateError(err)
+		content = cleanCommitMessage(content)
+	}
+
+	if err := saveSession(client, session, model); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	noCommit, _ := cmd.Flags().GetBool("no-commit")
 	if !noCommit {
 		commitMessage := strings.SplitN(content, "\n", 2)
 		commitTitle := commitMessage[0]
@@ -258,7 +322,61 @@ func commitRunner(cmd *cobra.Command, args []string) {
 			fmt.Printf("Error: Failed to commit changes. Details: %v\n", err)
 			os.Exit(1)
 		}
-	} else {
-		fmt.Println(content)
 	}
 }
+
+// chooseCommitCandidate generates several messages and returns the one the
+// user picks. It returns nothing when the candidates were only printed, i.e.
+// with --no-commit or when not run interactively.
+func chooseCommitCandidate(ctx context.Context, client copilot.Copilot, prompt string, model string, n int, noCommit bool) string {
+	temperature := candidateTemperature
+	candidates, err := client.AskCandidates(ctx, prompt, &copilot.AskOptions{
+		Model:       model,
+		N:           n,
+		Temperature: &temperature,
+	})
+	exitOnGenerateError(err)
+	for i := range candidates {
+		candidates[i] = cleanCommitMessage(candidates[i])
+	}
+
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		if err := printCandidatesJSON(candidates); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return ""
+	}
+
+	printCandidates(candidates)
+	if noCommit {
+		return ""
+	}
+
+	content, err := chooseCandidate("message", candidates)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	client.SetHistory(append(client.History(), copilot.Exchange(prompt, content)...))
+	return content
+}
+
+func exitOnGenerateError(err error) {
+	if errors.Is(err, copilot.ErrTruncated) {
+		fmt.Println("Error: The commit message was cut off at the model's output limit, refusing to commit an incomplete message. Try again or pick another model with --model.")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Printf("Error: Failed to generate commit message. Details: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// cleanCommitMessage removes code block wrappers and surrounding newlines
+// from a generated message.
+func cleanCommitMessage(content string) string {
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+	return strings.Trim(content, "\n")
+}
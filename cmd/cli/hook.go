@@ -0,0 +1,317 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/mr687/lazycopilot/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	hookName = "prepare-commit-msg"
+	// hookMarker identifies the hooks installed by lazycopilot
+	hookMarker = "# Installed by lazycopilot"
+	// hookBackupSuffix is appended to the name of a hook that existed before
+	// installing, which the installed hook runs first
+	hookBackupSuffix = ".pre-lazycopilot"
+
+	defaultHookTimeout = 30 * time.Second
+)
+
+const hookScript = `#!/bin/sh
+` + hookMarker + `, remove it with 'lazycopilot hook uninstall'.
+
+# Run the hook that was installed before
+if [ -x "$0` + hookBackupSuffix + `" ]; then
+	"$0` + hookBackupSuffix + `" "$@" || exit $?
+fi
+
+LAZYCOPILOT=%s
+[ -x "$LAZYCOPILOT" ] || LAZYCOPILOT=$(command -v lazycopilot) || exit 0
+
+# Failing to generate a message never blocks the commit
+"$LAZYCOPILOT" hook run %s -- "$@" </dev/null || true
+exit 0
+`
+
+func newHookCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hook",
+		Short: "Generate commit messages on plain git commit with a prepare-commit-msg hook",
+	}
+
+	cmd.AddCommand(
+		newHookInstallCommand(),
+		newHookUninstallCommand(),
+		newHookStatusCommand(),
+		newHookRunCommand(),
+	)
+
+	return cmd
+}
+
+func newHookInstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install the prepare-commit-msg hook in the repository",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			model, _ := cmd.Flags().GetString("model")
+
+			hookPath, err := hookFilePath(cmd)
+			if err != nil {
+				return err
+			}
+
+			installed, err := isLazycopilotHook(hookPath)
+			if err != nil {
+				return err
+			}
+			chained := false
+			if !installed && utils.IsFileExists(hookPath) {
+				if utils.IsFileExists(hookPath + hookBackupSuffix) {
+					return fmt.Errorf("both %s and %s exist, remove one of them first", hookPath, hookPath+hookBackupSuffix)
+				}
+				if err := os.Rename(hookPath, hookPath+hookBackupSuffix); err != nil {
+					return fmt.Errorf("failed to move the existing hook: %w", err)
+				}
+				chained = true
+			}
+
+			exe, err := os.Executable()
+			if err != nil {
+				exe = "lazycopilot"
+			}
+			runArgs := []string{"--timeout", timeout.String()}
+			if model != "" {
+				runArgs = append(runArgs, "--model", shellQuote(model))
+			}
+
+			if err := os.MkdirAll(filepath.Dir(hookPath), 0o755); err != nil {
+				return err
+			}
+			script := fmt.Sprintf(hookScript, shellQuote(exe), strings.Join(runArgs, " "))
+			if err := os.WriteFile(hookPath, []byte(script), 0o755); err != nil {
+				return fmt.Errorf("failed to write the hook: %w", err)
+			}
+
+			fmt.Printf("Installed the %s hook in %s\n", hookName, filepath.Dir(hookPath))
+			if chained {
+				fmt.Printf("The existing hook was moved to %s and still runs first\n", filepath.Base(hookPath+hookBackupSuffix))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringP("path", "p", "", "Path to the repository (default is current directory)")
+	cmd.Flags().Duration("timeout", defaultHookTimeout, "Give up generating a message after this long")
+	cmd.Flags().StringP("model", "m", "", "Model used to generate the message (default is the configured default model)")
+	return cmd
+}
+
+func newHookUninstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the prepare-commit-msg hook and restore the previous one",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hookPath, err := hookFilePath(cmd)
+			if err != nil {
+				return err
+			}
+
+			installed, err := isLazycopilotHook(hookPath)
+			if err != nil {
+				return err
+			}
+			if !installed {
+				return fmt.Errorf("no %s hook installed by lazycopilot in %s", hookName, filepath.Dir(hookPath))
+			}
+
+			if err := os.Remove(hookPath); err != nil {
+				return fmt.Errorf("failed to remove the hook: %w", err)
+			}
+			fmt.Printf("Removed the %s hook from %s\n", hookName, filepath.Dir(hookPath))
+
+			if utils.IsFileExists(hookPath + hookBackupSuffix) {
+				if err := os.Rename(hookPath+hookBackupSuffix, hookPath); err != nil {
+					return fmt.Errorf("failed to restore the previous hook: %w", err)
+				}
+				fmt.Println("Restored the previous hook")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringP("path", "p", "", "Path to the repository (default is current directory)")
+	return cmd
+}
+
+func newHookStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the prepare-commit-msg hook is installed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hookPath, err := hookFilePath(cmd)
+			if err != nil {
+				return err
+			}
+
+			installed, err := isLazycopilotHook(hookPath)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Hooks directory: %s\n", filepath.Dir(hookPath))
+			switch {
+			case installed && utils.IsFileExists(hookPath+hookBackupSuffix):
+				fmt.Printf("%s: installed, runs %s first\n", hookName, filepath.Base(hookPath+hookBackupSuffix))
+			case installed:
+				fmt.Printf("%s: installed\n", hookName)
+			case utils.IsFileExists(hookPath):
+				fmt.Printf("%s: not installed, another hook exists and will be chained on install\n", hookName)
+			default:
+				fmt.Printf("%s: not installed\n", hookName)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringP("path", "p", "", "Path to the repository (default is current directory)")
+	return cmd
+}
+
+func newHookRunCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "run <message-file> [source] [commit]",
+		Short:  "Fill the commit message file, called by the prepare-commit-msg hook",
+		Hidden: true,
+		Args:   cobra.RangeArgs(1, 3),
+		// Problems are reported but never fail the hook
+		RunE: func(cmd *cobra.Command, args []string) error {
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			model, _ := cmd.Flags().GetString("model")
+
+			// A source means the message comes from -m, -F, a template, a
+			// merge, a squash or an existing commit as with --amend
+			if len(args) > 1 && args[1] != "" {
+				return nil
+			}
+
+			if err := runHook(args[0], model, timeout); err != nil {
+				fmt.Fprintf(os.Stderr, "lazycopilot: no commit message generated: %v\n", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Duration("timeout", defaultHookTimeout, "Give up generating a message after this long")
+	cmd.Flags().StringP("model", "m", "", "Model used to generate the message (default is the configured default model)")
+	return cmd
+}
+
+// runHook writes a generated message above the comments git put in the
+// message file.
+func runHook(messageFile string, model string, timeout time.Duration) error {
+	current, err := os.ReadFile(messageFile)
+	if err != nil {
+		return err
+	}
+
+	// Git runs hooks from the top level directory
+	path, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	commentChar := utils.GetCommentChar(path)
+	if commentChar == "auto" {
+		commentChar = guessCommentChar(string(current))
+	}
+	if hasMessage(string(current), commentChar) {
+		return nil
+	}
+	diff := utils.GetDiff(path, true)
+	if diff == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	fmt.Fprintln(os.Stderr, "lazycopilot: generating the commit message...")
+	content, err := generateCommitMessage(ctx, copilot.NewCopilot(), diff, diffPromptOptions{Model: model})
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(messageFile, []byte(content+"\n"+string(current)), 0o644)
+}
+
+// autoCommentChars are the characters git picks the comment character from
+// when core.commentChar is auto.
+const autoCommentChars = "#;@!$%^&|:"
+
+// hasMessage reports whether the message file holds more than comments. The
+// diff of git commit --verbose below the scissors line is ignored. The
+// comment character defaults to #.
+func hasMessage(content, commentChar string) bool {
+	if commentChar == "" {
+		commentChar = "#"
+	}
+	scissors := commentChar + " ------------------------ >8 ------------------------"
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimRight(line, "\r") == scissors {
+			break
+		}
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, commentChar) {
+			return true
+		}
+	}
+	return false
+}
+
+// guessCommentChar returns the comment character git picked for a message
+// file without a message, the first one its comments start with.
+func guessCommentChar(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if line != "" && strings.ContainsRune(autoCommentChars, rune(line[0])) {
+			return line[:1]
+		}
+	}
+	return "#"
+}
+
+func hookFilePath(cmd *cobra.Command) (string, error) {
+	path, _ := cmd.Flags().GetString("path")
+	if path == "" {
+		path, _ = os.Getwd()
+	}
+
+	hooks, err := utils.GetHooksPath(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to find the hooks directory: %w", err)
+	}
+	return filepath.Join(hooks, hookName), nil
+}
+
+func isLazycopilotHook(hookPath string) (bool, error) {
+	content, err := os.ReadFile(hookPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(string(content), hookMarker), nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
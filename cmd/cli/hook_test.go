@@ -0,0 +1,74 @@
+package cli
+
+import "testing"
+
+func TestHasMessage(t *testing.T) {
+	const template = `
+# Please enter the commit message for your changes. Lines starting
+# with '#' will be ignored, and an empty message aborts the commit.
+#
+# On branch main
+# Changes to be committed:
+#	modified:   main.go
+#
+`
+	const verbose = template + `# ------------------------ >8 ------------------------
+# Do not modify or remove the line above.
+# Everything below it will be ignored.
+diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-package old
++package main
+`
+
+	tests := []struct {
+		name        string
+		content     string
+		commentChar string
+		want        bool
+	}{
+		{"empty", "", "", false},
+		{"template", template, "", false},
+		{"message", "fix: handle empty diffs\n" + template, "", true},
+		{"verbose template", verbose, "", false},
+		{"verbose with message", "fix: handle empty diffs\n" + verbose, "#", true},
+		{"comment char", "; Please enter the commit message\n;\n", ";", false},
+		{"hash with other comment char", "#123 is fixed\n; Please enter the commit message\n", ";", true},
+		{
+			name:        "verbose with comment char",
+			content:     "; Please enter the commit message\n; ------------------------ >8 ------------------------\ndiff --git a/a b/a\n",
+			commentChar: ";",
+			want:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasMessage(tt.content, tt.commentChar); got != tt.want {
+				t.Errorf("hasMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuessCommentChar(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"hash", "\n# Please enter the commit message\n", "#"},
+		{"semicolon", "\n; Please enter the commit message\n", ";"},
+		{"no comments", "", "#"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := guessCommentChar(tt.content); got != tt.want {
+				t.Errorf("guessCommentChar() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/spf13/cobra"
+)
+
+func newModelsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "models",
+		Short: "Inspect and select Copilot models",
+	}
+
+	cmd.AddCommand(
+		newModelsListCommand(),
+		newModelsShowCommand(),
+		newModelsDefaultCommand(),
+		newModelsRefreshCommand(),
+	)
+
+	return cmd
+}
+
+func newModelsListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List all available models",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			models, err := copilot.NewCopilot().FetchModels(context.Background())
+			if err != nil {
+				return fmt.Errorf("failed to fetch models: %v", err)
+			}
+
+			defaultModel := currentDefaultModel()
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tVENDOR\tPREVIEW\tDEFAULT")
+			for _, id := range sortedModelIDs(models) {
+				model := models[id]
+				isDefault := ""
+				if id == defaultModel {
+					isDefault = "*"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", model.ID, model.Name, model.Vendor, model.Preview, isDefault)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newModelsShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show the capabilities and limits of a model",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// GetModel refreshes the cache for models released since
+			model, err := copilot.NewCopilot().GetModel(context.Background(), args[0])
+			if err != nil {
+				return fmt.Errorf("%v. Use 'lazycopilot models list' to see available models", err)
+			}
+
+			capabilities := model.Capabilities
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "ID:\t%s\n", model.ID)
+			fmt.Fprintf(w, "Name:\t%s\n", model.Name)
+			fmt.Fprintf(w, "Vendor:\t%s\n", model.Vendor)
+			fmt.Fprintf(w, "Version:\t%s\n", model.Version)
+			fmt.Fprintf(w, "Family:\t%s\n", capabilities.Family)
+			fmt.Fprintf(w, "Type:\t%s\n", capabilities.Type)
+			fmt.Fprintf(w, "Tokenizer:\t%s\n", capabilities.Tokenizer)
+			fmt.Fprintf(w, "Preview:\t%t\n", model.Preview)
+			fmt.Fprintf(w, "Streaming:\t%t\n", capabilities.Supports.Streaming)
+			fmt.Fprintf(w, "Tool calls:\t%t\n", capabilities.Supports.ToolCalls)
+			fmt.Fprintf(w, "Max context window tokens:\t%d\n", capabilities.Limits.MaxContextWindowTokens)
+			fmt.Fprintf(w, "Max prompt tokens:\t%d\n", capabilities.Limits.MaxPromptTokens)
+			fmt.Fprintf(w, "Max output tokens:\t%d\n", capabilities.Limits.MaxOutputTokens)
+			return w.Flush()
+		},
+	}
+}
+
+func newModelsDefaultCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "default [id]",
+		Short: "Show or set the default model",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				fmt.Println(currentDefaultModel())
+				return nil
+			}
+
+			id := args[0]
+			if _, err := copilot.NewCopilot().GetModel(context.Background(), id); err != nil {
+				return fmt.Errorf("%v. Use 'lazycopilot models list' to see available models", err)
+			}
+
+			settings := config.LoadSettings()
+			settings.SetDefaultModel(id)
+			if err := config.SaveSettings(settings); err != nil {
+				return fmt.Errorf("failed to save settings: %v", err)
+			}
+
+			fmt.Printf("Default model set to '%s'\n", id)
+			return nil
+		},
+	}
+}
+
+func newModelsRefreshCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "refresh",
+		Short: "Fetch the model list again, ignoring the cache",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			models, err := copilot.NewCopilot().RefreshModels(context.Background())
+			if err != nil {
+				return fmt.Errorf("failed to refresh models: %v", err)
+			}
+
+			fmt.Printf("Successfully refreshed %d models\n", len(models))
+			return nil
+		},
+	}
+}
+
+func currentDefaultModel() string {
+	settings := config.LoadSettings()
+	if model := settings.GetDefaultModel(); model != "" {
+		return model
+	}
+	if settings.GetProvider() != config.PROVIDER_COPILOT {
+		return ""
+	}
+	return copilot.DefaultModel
+}
+
+func sortedModelIDs(models map[string]*copilot.Model) []string {
+	ids := make([]string, 0, len(models))
+	for id := range models {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/mr687/lazycopilot/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// prTemplateName is the file name GitHub looks for, in any case.
+const prTemplateName = "pull_request_template.md"
+
+// prTemplateDirs are the directories GitHub looks for a pull request
+// template in, relative to the top level directory, in order.
+var prTemplateDirs = []string{".github", "", "docs"}
+
+func newPRCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pr",
+		Short: "Work with pull requests",
+	}
+
+	cmd.AddCommand(
+		newPRDescribeCommand(),
+	)
+
+	return cmd
+}
+
+func newPRDescribeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "describe",
+		Short: "Generate a title and description for a pull request of the current branch",
+		Long: `Generate a title and Markdown description for a pull request of the current
+branch from its commits since base, the diff stat and the diff. When the
+repository has a pull request template, the description fills in its sections.
+
+The title is printed on the first line and the description below it. With
+--output the description is written to the file instead and only the title is
+printed, which fits gh:
+
+  gh pr create --title "$(lazycopilot pr describe -o body.md)" --body-file body.md`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, _ := cmd.Flags().GetString("path")
+			if path == "" {
+				path, _ = os.Getwd()
+			}
+			base, _ := cmd.Flags().GetString("base")
+			head, _ := cmd.Flags().GetString("head")
+			model, _ := cmd.Flags().GetString("model")
+			output, _ := cmd.Flags().GetString("output")
+			templateFile, _ := cmd.Flags().GetString("template")
+
+			if _, err := utils.ResolveRevision(path, base); err != nil {
+				return fmt.Errorf("base branch '%s' not found, choose it with --base: %w", base, err)
+			}
+			commits, err := utils.ListCommits(path, base+".."+head, "--no-merges")
+			if err != nil {
+				return fmt.Errorf("failed to list the commits of %s..%s: %w", base, head, err)
+			}
+			diff := utils.GetDiff(path, false, base+"..."+head)
+			if len(commits) == 0 || diff == "" {
+				return fmt.Errorf("no changes between %s and %s", base, head)
+			}
+			stat, err := utils.GetDiffStat(path, base+"..."+head)
+			if err != nil {
+				return err
+			}
+			list, err := formatCommitList(path, commits)
+			if err != nil {
+				return err
+			}
+
+			if templateFile == "" {
+				if templateFile, err = findPRTemplate(path); err != nil {
+					return err
+				}
+			}
+			values := map[string]string{"{{commits}}": list, "{{stat}}": stat}
+			suffix := ""
+			if templateFile != "" {
+				template, err := os.ReadFile(templateFile)
+				if err != nil {
+					return fmt.Errorf("failed to read the pull request template: %w", err)
+				}
+				fmt.Fprintf(os.Stderr, "Using the pull request template %s\n", templateFile)
+				suffix = "\n\n" + config.PR_TEMPLATE_INSTRUCTIONS
+				values["{{template}}"] = strings.TrimSpace(string(template))
+			}
+
+			fmt.Fprintf(os.Stderr, "Describing %d commits...\n", len(commits))
+			title, body, err := generatePRDescription(context.Background(), copilot.NewCopilot(), diff, diffPromptOptions{
+				Template:          config.PR_PROMPT,
+				SummariesTemplate: config.PR_SUMMARIES_PROMPT,
+				Suffix:            suffix,
+				Values:            values,
+				Model:             model,
+				Summarize:         summarizeAuto,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to generate the description: %w", err)
+			}
+
+			if output == "" {
+				fmt.Printf("%s\n\n%s\n", title, body)
+				return nil
+			}
+			if err := os.WriteFile(output, []byte(body+"\n"), 0o644); err != nil {
+				return fmt.Errorf("failed to write the description: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "Wrote the description to %s\n", output)
+			fmt.Println(title)
+			return nil
+		},
+	}
+	cmd.Flags().StringP("path", "p", "", "Path to the repository (default is current directory)")
+	cmd.Flags().StringP("base", "b", "main", "Branch the pull request merges into")
+	cmd.Flags().String("head", "HEAD", "Branch or revision with the changes of the pull request")
+	cmd.Flags().StringP("model", "m", "", "Model used to generate the description (default is the configured default model)")
+	cmd.Flags().StringP("output", "o", "", "Write the description to this file and print only the title")
+	cmd.Flags().StringP("template", "t", "", "Pull request template to fill in (default is the template of the repository, if any)")
+	return cmd
+}
+
+// generatePRDescription returns the title and body the model wrote for the
+// changes.
+func generatePRDescription(ctx context.Context, client copilot.Copilot, diff string, opts diffPromptOptions) (string, string, error) {
+	prompt, err := buildDiffPrompt(ctx, client, diff, opts)
+	if err != nil {
+		return "", "", err
+	}
+
+	content, err := client.NewConversation().Ask(ctx, prompt, &copilot.AskOptions{Model: opts.Model})
+	if err != nil {
+		return "", "", err
+	}
+
+	title, body, _ := strings.Cut(trimCodeBlock(content), "\n")
+	title = strings.TrimSpace(strings.TrimLeft(title, "# "))
+	title = strings.TrimSpace(strings.TrimPrefix(title, "Title:"))
+	if title == "" {
+		return "", "", errors.New("the response has no title")
+	}
+	return title, strings.TrimSpace(body), nil
+}
+
+// findPRTemplate returns the pull request template of the repository at
+// path, or nothing when it has none.
+func findPRTemplate(path string) (string, error) {
+	root, err := utils.GetRepoRoot(path)
+	if err != nil {
+		return "", err
+	}
+
+	for _, dir := range prTemplateDirs {
+		entries, err := os.ReadDir(filepath.Join(root, dir))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.EqualFold(entry.Name(), prTemplateName) {
+				return filepath.Join(root, dir, entry.Name()), nil
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/mr687/lazycopilot/pkg/commit"
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/mr687/lazycopilot/pkg/tokenizer"
+)
+
+// promptOverheadTokens leaves room for the message framing of the request,
+// which the tokenizer doesn't see.
+const promptOverheadTokens = 100
+
+// messageOverheadTokens is the framing of every message sent along.
+const messageOverheadTokens = 4
+
+// summaryParallelism bounds the concurrent requests made while summarizing
+// the parts of a large diff.
+const summaryParallelism = 4
+
+const (
+	summarizeAuto   = "auto"
+	summarizeAlways = "always"
+	summarizeNever  = "never"
+)
+
+var summarizeModes = []string{summarizeAuto, summarizeAlways, summarizeNever}
+
+func isValidSummarizeMode(mode string) bool {
+	return slices.Contains(summarizeModes, mode)
+}
+
+type diffPromptOptions struct {
+	// Template contains the {{diff}} placeholder.
+	Template string
+	// SummariesTemplate contains the {{summaries}} placeholder and is used
+	// instead of Template when the diff is summarized.
+	SummariesTemplate string
+	// Suffix is appended to either template.
+	Suffix string
+	// Values fill the other placeholders of the templates, in the same pass
+	// as the diff so that they can't bring in placeholders of their own.
+	Values    map[string]string
+	Model     string
+	Summarize string
+	// SystemPrompt and History are sent along with the prompt and take
+	// their share of the budget. SystemPrompt defaults to the Copilot
+	// instructions.
+	SystemPrompt string
+	History      []copilot.PromptMessage
+}
+
+// buildDiffPrompt fills a prompt template with the diff. A diff that exceeds
+// the prompt budget of the model is summarized in parts first, or trimmed
+// when summarizing is disabled, reporting what was cut on stderr.
+func buildDiffPrompt(ctx context.Context, client copilot.Copilot, diff string, opts diffPromptOptions) (string, error) {
+	template := opts.Template + opts.Suffix
+
+	// fill replaces the placeholder and the values in template at once
+	fill := func(template, placeholder, content string) string {
+		oldnew := []string{placeholder, content}
+		for key, value := range opts.Values {
+			oldnew = append(oldnew, key, value)
+		}
+		return strings.NewReplacer(oldnew...).Replace(template)
+	}
+
+	modelConfig, err := client.GetModel(ctx, opts.Model)
+	if err != nil {
+		return "", err
+	}
+
+	maxPromptTokens := modelConfig.Capabilities.Limits.MaxPromptTokens
+	if maxPromptTokens <= 0 {
+		return fill(template, "{{diff}}", diff), nil
+	}
+
+	tok, err := tokenizer.New(modelConfig.Capabilities.Tokenizer)
+	if err != nil {
+		return "", err
+	}
+
+	systemPrompt := opts.SystemPrompt
+	if systemPrompt == "" {
+		systemPrompt = copilot.COPILOT_INSTRUCTIONS
+	}
+	sent := tok.Count(systemPrompt) + promptOverheadTokens
+	for _, message := range opts.History {
+		sent += tok.Count(message.Content) + messageOverheadTokens
+	}
+
+	// budget returns the tokens left for the placeholder of a template sent
+	// along with the system prompt and the history
+	budget := func(template string) int {
+		return maxPromptTokens - sent - tok.Count(template)
+	}
+
+	diffBudget := budget(fill(template, "{{diff}}", ""))
+	if diffBudget <= 0 {
+		return "", fmt.Errorf("the prompt leaves no room for the diff within the prompt limit of %s (%d tokens)%s", modelConfig.ID, maxPromptTokens, historyHint(opts.History))
+	}
+	summarize := opts.SummariesTemplate != "" &&
+		(opts.Summarize == summarizeAlways || (opts.Summarize == summarizeAuto && tok.Count(diff) > diffBudget))
+	if !summarize {
+		result := commit.FitDiff(diff, diffBudget, tok)
+		if len(result.Truncated) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: The diff exceeds the prompt limit of %s, truncated: %s\n", modelConfig.ID, strings.Join(result.Truncated, ", "))
+		}
+		if len(result.Omitted) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: The diff exceeds the prompt limit of %s, omitted: %s\n", modelConfig.ID, strings.Join(result.Omitted, ", "))
+		}
+		return fill(template, "{{diff}}", result.Diff), nil
+	}
+
+	// The parts are summarized in conversations of their own
+	chunkBudget := maxPromptTokens - tok.Count(copilot.COPILOT_INSTRUCTIONS) - promptOverheadTokens - tok.Count(strings.ReplaceAll(config.DIFF_SUMMARY_PROMPT, "{{diff}}", ""))
+	chunks, err := commit.ChunkDiff(diff, chunkBudget, tok)
+	if err != nil {
+		return "", fmt.Errorf("the summary prompt leaves no room for the diff within the prompt limit of %s (%d tokens): %w", modelConfig.ID, maxPromptTokens, err)
+	}
+	fmt.Fprintf(os.Stderr, "Summarizing the diff in %d parts...\n", len(chunks))
+
+	summaries, err := commit.SummarizeChunks(ctx, client, chunks, copilot.AskOptions{Model: modelConfig.ID}, summaryParallelism)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize the diff: %w", err)
+	}
+
+	template = opts.SummariesTemplate + opts.Suffix
+	summariesBudget := budget(fill(template, "{{summaries}}", ""))
+	if summariesBudget <= 0 {
+		return "", fmt.Errorf("the prompt leaves no room for the summaries within the prompt limit of %s (%d tokens)%s", modelConfig.ID, maxPromptTokens, historyHint(opts.History))
+	}
+	joined := tok.Truncate(strings.Join(summaries, "\n\n"), summariesBudget)
+	return fill(template, "{{summaries}}", joined), nil
+}
+
+func historyHint(history []copilot.PromptMessage) string {
+	if len(history) == 0 {
+		return ""
+	}
+	return ", continue in a new session"
+}
+
+// generateCommitMessage writes a commit message for diff in a conversation
+// of its own, leaving the history of the client untouched. The templates
+// default to the commit prompts.
+func generateCommitMessage(ctx context.Context, client copilot.Copilot, diff string, opts diffPromptOptions) (string, error) {
+	if opts.Template == "" {
+		opts.Template = config.COMMIT_PROMPT
+		opts.SummariesTemplate = config.COMMIT_SUMMARIES_PROMPT
+	}
+	if opts.Summarize == "" {
+		opts.Summarize = summarizeAuto
+	}
+
+	prompt, err := buildDiffPrompt(ctx, client, diff, opts)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := client.NewConversation().Ask(ctx, prompt, &copilot.AskOptions{Model: opts.Model})
+	if err != nil {
+		return "", err
+	}
+	return cleanCommitMessage(content), nil
+}
@@ -0,0 +1,316 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mr687/lazycopilot/pkg/commit"
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/mr687/lazycopilot/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// reword is a commit whose message is replaced.
+type reword struct {
+	Commit     string
+	OldMessage string
+	NewMessage string
+}
+
+func newCommitRewordCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reword <rev-range>",
+		Short: "Regenerate the messages of existing commits from their own changes",
+		Long: `Regenerate the messages of existing commits from their own changes and
+rewrite the history of the current branch with them.
+
+The range is given as for git log, e.g. HEAD~3.. for the last three commits.
+A single revision rewords just that commit. Commits already on the upstream
+branch are refused unless --force is given.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, _ := cmd.Flags().GetString("path")
+			if path == "" {
+				path, _ = os.Getwd()
+			}
+			force, _ := cmd.Flags().GetBool("force")
+			yes, _ := cmd.Flags().GetBool("yes")
+			model, _ := cmd.Flags().GetString("model")
+			style, _ := cmd.Flags().GetString("style")
+			if !commit.IsValidStyle(style) {
+				return fmt.Errorf("invalid style '%s'. Available styles: %s", style, strings.Join(commit.GetAvailableStyles(), ", "))
+			}
+
+			commits, err := rewordCommits(path, args[0])
+			if err != nil {
+				return err
+			}
+			if err := checkRewritable(path, commits, force); err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			client := copilot.NewCopilot()
+			rewords := make([]reword, 0, len(commits))
+			for i, sha := range commits {
+				fmt.Fprintf(os.Stderr, "Generating message %d/%d for %s...\n", i+1, len(commits), sha[:7])
+				r, err := generateReword(ctx, client, path, sha, model, commit.GetStylePrompt(commit.Style(style)))
+				if err != nil {
+					return err
+				}
+				rewords = append(rewords, r)
+			}
+
+			for _, r := range rewords {
+				printReword(r)
+			}
+			if !yes && !confirm("Rewrite these commits?") {
+				fmt.Println("Reword cancelled.")
+				return nil
+			}
+
+			if err := rewriteMessages(path, rewords); err != nil {
+				return err
+			}
+			fmt.Printf("Reworded %d commits\n", len(rewords))
+			return nil
+		},
+	}
+	cmd.Flags().StringP("path", "p", "", "Path to the repository (default is current directory)")
+	cmd.Flags().BoolP("force", "f", false, "Reword commits that are already on the upstream branch")
+	cmd.Flags().BoolP("yes", "y", false, "Rewrite without asking for confirmation")
+	cmd.Flags().StringP("model", "m", "", "Model used to generate the messages (default is the configured default model)")
+	cmd.Flags().StringP("style", "S", "normal", fmt.Sprintf("Style of the commit titles: %s", strings.Join(commit.GetAvailableStyles(), ", ")))
+	return cmd
+}
+
+// rewordCommits returns the commits of revRange, oldest first. A single
+// revision stands for just that commit.
+func rewordCommits(path, revRange string) ([]string, error) {
+	if !strings.Contains(revRange, "..") {
+		sha, err := utils.ResolveRevision(path, revRange)
+		if err != nil {
+			return nil, err
+		}
+		return []string{sha}, nil
+	}
+
+	commits, err := utils.ListCommits(path, revRange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the commits of %s: %w", revRange, err)
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commits in %s", revRange)
+	}
+	return commits, nil
+}
+
+// checkRewritable makes sure the commits can be rewritten without surprises:
+// they belong to the current branch, nothing between them and HEAD is a
+// merge and, unless forced, none of them was pushed upstream.
+func checkRewritable(path string, commits []string, force bool) error {
+	dirty, err := utils.HasUncommittedChanges(path)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return errors.New("there are uncommitted changes, commit or stash them first")
+	}
+
+	for _, sha := range commits {
+		ok, err := utils.IsAncestor(path, sha, "HEAD")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("commit %s is not on the current branch", sha[:7])
+		}
+	}
+
+	merges, err := utils.ListCommits(path, rebaseRange(rebaseBase(path, commits[0])), "--merges")
+	if err != nil {
+		return err
+	}
+	if len(merges) > 0 {
+		return fmt.Errorf("merge commit %s can't be rewritten, reword a range without merges", merges[0][:7])
+	}
+
+	upstream := utils.GetUpstream(path)
+	if upstream == "" || force {
+		return nil
+	}
+	for _, sha := range commits {
+		pushed, err := utils.IsAncestor(path, sha, upstream)
+		if err != nil {
+			return err
+		}
+		if pushed {
+			return fmt.Errorf("commit %s is already on %s, rewording it rewrites published history. Use --force to reword it anyway", sha[:7], upstream)
+		}
+	}
+	return nil
+}
+
+func generateReword(ctx context.Context, client copilot.Copilot, path, sha, model, suffix string) (reword, error) {
+	oldMessage, err := utils.GetCommitMessage(path, sha)
+	if err != nil {
+		return reword{}, err
+	}
+	diff, err := utils.GetCommitDiff(path, sha)
+	if err != nil {
+		return reword{}, err
+	}
+	if diff == "" {
+		return reword{}, fmt.Errorf("commit %s has no changes to describe", sha[:7])
+	}
+
+	trailers, err := utils.ParseTrailers(path, oldMessage)
+	if err != nil {
+		return reword{}, fmt.Errorf("failed to read the trailers of %s: %w", sha[:7], err)
+	}
+
+	message, err := generateCommitMessage(ctx, client, diff, diffPromptOptions{Suffix: suffix, Model: model})
+	if err != nil {
+		return reword{}, fmt.Errorf("failed to generate the message of %s: %w", sha[:7], err)
+	}
+	return reword{Commit: sha, OldMessage: oldMessage, NewMessage: appendTrailers(message, trailers)}, nil
+}
+
+// appendTrailers keeps the trailers of the old message, such as
+// Signed-off-by, below the new one, skipping those it already has.
+func appendTrailers(message string, trailers []string) string {
+	lines := strings.Split(message, "\n")
+	missing := make([]string, 0, len(trailers))
+	for _, trailer := range trailers {
+		if !slices.Contains(lines, trailer) && !slices.Contains(missing, trailer) {
+			missing = append(missing, trailer)
+		}
+	}
+	if len(missing) == 0 {
+		return message
+	}
+	return message + "\n\n" + strings.Join(missing, "\n")
+}
+
+func printReword(r reword) {
+	fmt.Printf("commit %s\n", r.Commit[:7])
+	for _, line := range strings.Split(r.OldMessage, "\n") {
+		fmt.Printf("- %s\n", line)
+	}
+	for _, line := range strings.Split(r.NewMessage, "\n") {
+		fmt.Printf("+ %s\n", line)
+	}
+	fmt.Println()
+}
+
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(response)) == "y"
+}
+
+// rewriteMessages replaces the messages of the commits. HEAD alone is
+// amended, anything older is rewritten by a rebase that amends every
+// reworded commit right after picking it.
+func rewriteMessages(path string, rewords []reword) error {
+	dir, err := os.MkdirTemp("", "lazycopilot-reword")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	messageFiles := make(map[string]string, len(rewords))
+	for _, r := range rewords {
+		file := filepath.Join(dir, r.Commit)
+		if err := os.WriteFile(file, []byte(r.NewMessage+"\n"), 0o644); err != nil {
+			return err
+		}
+		messageFiles[r.Commit] = file
+	}
+
+	head, err := utils.ResolveRevision(path, "HEAD")
+	if err != nil {
+		return err
+	}
+	if len(rewords) == 1 && rewords[0].Commit == head {
+		return runGit(path, nil, amendArgs(messageFiles[head])...)
+	}
+
+	base := rebaseBase(path, rewords[0].Commit)
+	commits, err := utils.ListCommits(path, rebaseRange(base))
+	if err != nil {
+		return err
+	}
+
+	var todo strings.Builder
+	for _, sha := range commits {
+		todo.WriteString("pick " + sha + "\n")
+		if file, ok := messageFiles[sha]; ok {
+			todo.WriteString("exec git " + strings.Join(quoteAll(amendArgs(file)), " ") + "\n")
+		}
+	}
+	todoFile := filepath.Join(dir, "git-rebase-todo")
+	if err := os.WriteFile(todoFile, []byte(todo.String()), 0o644); err != nil {
+		return err
+	}
+
+	args := []string{"rebase", "--interactive", base}
+	if base == "" {
+		args = []string{"rebase", "--interactive", "--root"}
+	}
+	// The generated todo replaces the one git asks to edit
+	env := []string{"GIT_SEQUENCE_EDITOR=cp " + shellQuote(todoFile)}
+	if err := runGit(path, env, args...); err != nil {
+		return fmt.Errorf("%w. Run 'git rebase --abort' to restore the branch", err)
+	}
+	return nil
+}
+
+// rebaseBase returns the parent of commit, or nothing for a root commit.
+func rebaseBase(path, commit string) string {
+	parent, err := utils.ResolveRevision(path, commit+"^")
+	if err != nil {
+		return ""
+	}
+	return parent
+}
+
+// rebaseRange returns the commits a rebase onto base replays.
+func rebaseRange(base string) string {
+	if base == "" {
+		return "HEAD"
+	}
+	return base + "..HEAD"
+}
+
+// amendArgs replaces the message of HEAD. Hooks are skipped since only the
+// message changes.
+func amendArgs(messageFile string) []string {
+	return []string{"commit", "--amend", "--allow-empty", "--no-verify", "--quiet", "-F", messageFile}
+}
+
+func quoteAll(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return quoted
+}
+
+func runGit(path string, env []string, args ...string) error {
+	cmd := exec.Command("git", append([]string{"-C", path}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return nil
+}
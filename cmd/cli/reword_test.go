@@ -0,0 +1,34 @@
+package cli
+
+import "testing"
+
+func TestAppendTrailers(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		trailers []string
+		want     string
+	}{
+		{"no trailers", "fix: handle empty diffs", nil, "fix: handle empty diffs"},
+		{
+			name:     "trailers",
+			message:  "fix: handle empty diffs\n\nThe diff may be empty.",
+			trailers: []string{"Signed-off-by: A <a@example.com>", "Change-Id: I123"},
+			want:     "fix: handle empty diffs\n\nThe diff may be empty.\n\nSigned-off-by: A <a@example.com>\nChange-Id: I123",
+		},
+		{
+			name:     "already there",
+			message:  "fix: handle empty diffs\n\nRefs: #12",
+			trailers: []string{"Refs: #12", "Signed-off-by: A <a@example.com>", "Signed-off-by: A <a@example.com>"},
+			want:     "fix: handle empty diffs\n\nRefs: #12\n\nSigned-off-by: A <a@example.com>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appendTrailers(tt.message, tt.trailers); got != tt.want {
+				t.Errorf("appendTrailers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
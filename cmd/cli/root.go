@@ -3,7 +3,10 @@ package cli
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/mr687/lazycopilot/pkg/usage"
+	"github.com/mr687/lazycopilot/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +20,15 @@ var rootCmd = &cobra.Command{
 		}
 		return fmt.Errorf("lazycopilot: %s is not a valid command. See 'lazycopilot --help'", args[0])
 	},
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Attribute the token usage of this run to the command and repository
+		path, _ := cmd.Flags().GetString("path")
+		if path == "" {
+			path, _ = os.Getwd()
+		}
+		repo, _ := utils.GetRepoRoot(path)
+		usage.SetContext(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" "), repo)
+	},
 }
 
 func init() {
@@ -24,6 +36,17 @@ func init() {
 
 	rootCmd.AddCommand(newAuthCommand())
 	rootCmd.AddCommand(newCommitCommand())
+	rootCmd.AddCommand(newModelsCommand())
+	rootCmd.AddCommand(newAgentsCommand())
+	rootCmd.AddCommand(newTokensCommand())
+	rootCmd.AddCommand(newAskCommand())
+	rootCmd.AddCommand(newChatCommand())
+	rootCmd.AddCommand(newSessionsCommand())
+	rootCmd.AddCommand(newUsageCommand())
+	rootCmd.AddCommand(newHookCommand())
+	rootCmd.AddCommand(newPRCommand())
+	rootCmd.AddCommand(newChangelogCommand())
+	rootCmd.AddCommand(newBranchCommand())
 }
 
 func Execute() {
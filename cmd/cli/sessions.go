@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/spf13/cobra"
+)
+
+func newSessionsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Manage saved conversation sessions",
+	}
+
+	cmd.AddCommand(
+		newSessionsListCommand(),
+		newSessionsShowCommand(),
+		newSessionsDeleteCommand(),
+	)
+
+	return cmd
+}
+
+func newSessionsListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List all saved sessions",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sessions, err := copilot.ListSessions()
+			if err != nil {
+				return fmt.Errorf("failed to list sessions: %v", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tMODEL\tMESSAGES\tUPDATED")
+			for _, session := range sessions {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", session.Name, session.Model, len(session.Messages), session.UpdatedAt.Format(time.DateTime))
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newSessionsShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <name>",
+		Short: "Print the messages of a session",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, err := copilot.LoadSession(args[0])
+			if err != nil {
+				return err
+			}
+
+			for _, message := range session.Messages {
+				fmt.Printf("[%s]\n%s\n\n", message.Role, message.Content)
+			}
+			return nil
+		},
+	}
+}
+
+func newSessionsDeleteCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a session",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := copilot.DeleteSession(args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("Successfully deleted session '%s'\n", args[0])
+			return nil
+		},
+	}
+}
+
+// openSession continues the named session on the client, creating it when it
+// doesn't exist yet. It returns nil when no name is given.
+func openSession(client copilot.Copilot, name string) (*copilot.Session, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	session, err := copilot.LoadOrNewSession(name)
+	if err != nil {
+		return nil, err
+	}
+	client.SetHistory(session.Messages)
+	return session, nil
+}
+
+// saveSession stores the history of the client in the session, if any.
+func saveSession(client copilot.Copilot, session *copilot.Session, model string) error {
+	if session == nil {
+		return nil
+	}
+
+	session.Messages = client.History()
+	if session.Messages == nil {
+		session.Messages = make([]copilot.PromptMessage, 0)
+	}
+	if model != "" {
+		session.Model = model
+	}
+	if err := copilot.SaveSession(session); err != nil {
+		return fmt.Errorf("failed to save session '%s': %v", session.Name, err)
+	}
+	return nil
+}
+
+// sessionModel returns the model to continue a session with when none was
+// picked explicitly.
+func sessionModel(session *copilot.Session, model string) string {
+	if model == "" && session != nil {
+		return session.Model
+	}
+	return model
+}
@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/mr687/lazycopilot/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// squashedCommitPattern matches the commits git lists in SQUASH_MSG.
+var squashedCommitPattern = regexp.MustCompile(`(?m)^commit ([0-9a-f]{40})`)
+
+func newCommitSquashMsgCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "squash-msg [<base>..<head>]",
+		Short: "Generate one message for squashing the commits of a range",
+		Long: `Generate one conventional commit message for squashing the commits of a
+range, e.g. main..feature, from their messages and their combined changes.
+
+With --write the message fills the message git prepared for the merge in
+progress: SQUASH_MSG after 'git merge --squash' or MERGE_MSG after
+'git merge --no-commit'. The range defaults to the merged commits then.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, _ := cmd.Flags().GetString("path")
+			if path == "" {
+				path, _ = os.Getwd()
+			}
+			model, _ := cmd.Flags().GetString("model")
+			write, _ := cmd.Flags().GetBool("write")
+
+			var (
+				commits []string
+				diff    string
+				err     error
+			)
+			switch {
+			case len(args) == 1:
+				commits, diff, err = squashRange(path, args[0])
+			case write:
+				commits, diff, err = mergeInProgress(path)
+			default:
+				return errors.New("a range such as main..feature is required")
+			}
+			if err != nil {
+				return err
+			}
+			if len(commits) == 0 || diff == "" {
+				return errors.New("no changes to squash")
+			}
+
+			messageFile := ""
+			if write {
+				if messageFile, err = mergeMessageFile(path); err != nil {
+					return err
+				}
+			}
+
+			list, err := formatCommitList(path, commits)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "Generating a message for %d commits...\n", len(commits))
+			message, err := generateCommitMessage(context.Background(), copilot.NewCopilot(), diff, diffPromptOptions{
+				Template:          config.SQUASH_PROMPT,
+				SummariesTemplate: config.SQUASH_SUMMARIES_PROMPT,
+				Values:            map[string]string{"{{commits}}": list},
+				Model:             model,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to generate the message: %w", err)
+			}
+
+			if !write {
+				fmt.Println(message)
+				return nil
+			}
+			if err := writeMergeMessage(messageFile, message); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote the message to %s, run 'git commit' to review it\n", messageFile)
+			return nil
+		},
+	}
+	cmd.Flags().StringP("path", "p", "", "Path to the repository (default is current directory)")
+	cmd.Flags().StringP("model", "m", "", "Model used to generate the message (default is the configured default model)")
+	cmd.Flags().BoolP("write", "w", false, "Fill SQUASH_MSG or MERGE_MSG of the merge in progress instead of printing the message")
+	return cmd
+}
+
+// squashRange returns the commits of revRange without merges, oldest first,
+// and the changes head introduced since it forked from base.
+func squashRange(path, revRange string) ([]string, string, error) {
+	base, head, ok := splitRange(revRange)
+	if !ok {
+		return nil, "", fmt.Errorf("invalid range '%s', use <base>..<head>", revRange)
+	}
+	if base == "" {
+		base = "HEAD"
+	}
+	if head == "" {
+		head = "HEAD"
+	}
+
+	commits, err := utils.ListCommits(path, base+".."+head, "--no-merges")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list the commits of %s: %w", revRange, err)
+	}
+	return commits, utils.GetDiff(path, false, base+"..."+head), nil
+}
+
+// splitRange splits a range given as <from>..<to> or <from>...<to>. Either
+// side may be empty.
+func splitRange(revRange string) (string, string, bool) {
+	from, to, ok := strings.Cut(revRange, "...")
+	if !ok {
+		from, to, ok = strings.Cut(revRange, "..")
+	}
+	return from, to, ok
+}
+
+// mergeInProgress returns the commits and changes of the merge git is in the
+// middle of.
+func mergeInProgress(path string) ([]string, string, error) {
+	mergeHead, err := utils.GetGitPath(path, "MERGE_HEAD")
+	if err != nil {
+		return nil, "", err
+	}
+	if utils.IsFileExists(mergeHead) {
+		return squashRange(path, "HEAD..MERGE_HEAD")
+	}
+
+	squashMsg, err := utils.GetGitPath(path, "SQUASH_MSG")
+	if err != nil {
+		return nil, "", err
+	}
+	content, err := os.ReadFile(squashMsg)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", errors.New("no merge in progress, run 'git merge --squash <branch>' first or give a range")
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A squash merge stages the changes and lists the commits newest first
+	commits := make([]string, 0)
+	for _, match := range squashedCommitPattern.FindAllStringSubmatch(string(content), -1) {
+		commits = append(commits, match[1])
+	}
+	slices.Reverse(commits)
+	return commits, utils.GetDiff(path, true), nil
+}
+
+// mergeMessageFile returns the message file of the merge in progress.
+func mergeMessageFile(path string) (string, error) {
+	squashMsg, err := utils.GetGitPath(path, "SQUASH_MSG")
+	if err != nil {
+		return "", err
+	}
+	if utils.IsFileExists(squashMsg) {
+		return squashMsg, nil
+	}
+
+	mergeHead, err := utils.GetGitPath(path, "MERGE_HEAD")
+	if err != nil {
+		return "", err
+	}
+	if utils.IsFileExists(mergeHead) {
+		return utils.GetGitPath(path, "MERGE_MSG")
+	}
+	return "", errors.New("no merge in progress to write the message for, run 'git merge --squash <branch>' first")
+}
+
+// writeMergeMessage replaces the message git prepared, keeping its comments
+// such as the list of conflicts.
+func writeMergeMessage(file, message string) error {
+	content := message + "\n"
+
+	current, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	comments := make([]string, 0)
+	for _, line := range strings.Split(string(current), "\n") {
+		if strings.HasPrefix(line, "#") {
+			comments = append(comments, line)
+		}
+	}
+	if len(comments) > 0 {
+		content += "\n" + strings.Join(comments, "\n") + "\n"
+	}
+
+	return os.WriteFile(file, []byte(content), 0o644)
+}
+
+// formatCommitList renders the messages of the commits as a list, bodies
+// indented below their titles.
+func formatCommitList(path string, commits []string) (string, error) {
+	items := make([]string, 0, len(commits))
+	for _, sha := range commits {
+		message, err := utils.GetCommitMessage(path, sha)
+		if err != nil {
+			return "", err
+		}
+		items = append(items, "- "+strings.ReplaceAll(message, "\n", "\n  "))
+	}
+	return strings.Join(items, "\n"), nil
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/mr687/lazycopilot/pkg/tokenizer"
+	"github.com/spf13/cobra"
+)
+
+func newTokensCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tokens",
+		Short: "Count the tokens of stdin for a model",
+		Example: `  git diff --staged | lazycopilot tokens
+  cat main.go | lazycopilot tokens --encoding o200k_base`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			model, _ := cmd.Flags().GetString("model")
+			encoding, _ := cmd.Flags().GetString("encoding")
+
+			maxPromptTokens := 0
+			if encoding == "" {
+				modelConfig, err := copilot.NewCopilot().GetModel(context.Background(), model)
+				if err != nil {
+					return fmt.Errorf("failed to look up model: %v", err)
+				}
+				encoding = modelConfig.Capabilities.Tokenizer
+				maxPromptTokens = modelConfig.Capabilities.Limits.MaxPromptTokens
+			}
+
+			tok, err := tokenizer.New(encoding)
+			if err != nil {
+				return err
+			}
+
+			input, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read stdin: %v", err)
+			}
+
+			count := tok.Count(string(input))
+			if maxPromptTokens > 0 {
+				fmt.Printf("%d / %d\n", count, maxPromptTokens)
+			} else {
+				fmt.Println(count)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringP("model", "m", "", "Model whose tokenizer is used (default is the configured default model)")
+	cmd.Flags().StringP("encoding", "e", "", fmt.Sprintf("Tokenizer to use instead of the model's: %s, %s", tokenizer.CL100K, tokenizer.O200K))
+	return cmd
+}
@@ -0,0 +1,16 @@
+package cli
+
+import (
+	"github.com/mr687/lazycopilot/pkg/copilot"
+)
+
+// registerGitTools lets the model inspect the history and files of the
+// repository at path while answering.
+func registerGitTools(client copilot.Copilot, path string) error {
+	for _, tool := range copilot.GitTools(path) {
+		if err := client.RegisterTool(tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
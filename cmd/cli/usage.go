@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mr687/lazycopilot/pkg/usage"
+	"github.com/spf13/cobra"
+)
+
+func newUsageCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Report the tokens used by lazycopilot",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sinceFlag, _ := cmd.Flags().GetString("since")
+			asJson, _ := cmd.Flags().GetBool("json")
+
+			since, err := parseSince(sinceFlag, time.Now())
+			if err != nil {
+				return err
+			}
+
+			records, err := usage.Load(since)
+			if err != nil {
+				return fmt.Errorf("failed to read the usage ledger: %v", err)
+			}
+			report := usage.NewReport(records, since)
+
+			if asJson {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(report)
+			}
+
+			fmt.Printf("Usage since %s: %d requests, %d tokens\n", since.Format(time.DateOnly), report.Total.Requests, report.Total.TotalTokens)
+			printUsageTotals("DAY", report.ByDay)
+			printUsageTotals("MODEL", report.ByModel)
+			printUsageTotals("COMMAND", report.ByCommand)
+			return nil
+		},
+	}
+	cmd.Flags().String("since", "30d", "Only report usage since a date (YYYY-MM-DD) or a number of days ago (e.g. 7d)")
+	cmd.Flags().Bool("json", false, "Print the report as JSON")
+	return cmd
+}
+
+func printUsageTotals(title string, totals []usage.Totals) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%s\tREQUESTS\tPROMPT\tCOMPLETION\tCACHED\tREASONING\tTOTAL\t\n", title)
+	for _, t := range totals {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t\n", t.Key, t.Requests, t.PromptTokens, t.CompletionTokens, t.CachedTokens, t.ReasoningTokens, t.TotalTokens)
+	}
+	w.Flush()
+}
+
+// parseSince accepts a date or a number of days before now.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n >= 0 {
+			y, m, d := now.AddDate(0, 0, -n).Date()
+			return time.Date(y, m, d, 0, 0, 0, 0, time.Local), nil
+		}
+	}
+	date, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since '%s': use a date (YYYY-MM-DD) or a number of days (e.g. 7d)", value)
+	}
+	return date, nil
+}
@@ -0,0 +1,137 @@
+package commit
+
+import (
+	"regexp"
+	"strings"
+)
+
+// conventionalTitle matches the title of a conventional commit, e.g.
+// "feat(cli)!: add the changelog command".
+var conventionalTitle = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?: +(.+)$`)
+
+// breakingFooter matches the footer that describes a breaking change.
+var breakingFooter = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE: *`)
+
+// ConventionalCommit is a commit parsed following the Conventional Commits
+// specification. Commits that don't follow it have no type.
+type ConventionalCommit struct {
+	Hash        string
+	Type        string
+	Scope       string
+	Description string
+	Body        string
+	Breaking    bool
+	// BreakingNote describes the breaking change when the commit has a
+	// BREAKING CHANGE footer.
+	BreakingNote string
+}
+
+// ChangelogGroup is a section of a changelog.
+type ChangelogGroup struct {
+	Title   string
+	Commits []ConventionalCommit
+}
+
+// BreakingChangesTitle is the title of the section listing breaking changes.
+const BreakingChangesTitle = "BREAKING CHANGES"
+
+// otherChangesTitle collects commits that don't follow the specification or
+// have an unknown type.
+const otherChangesTitle = "Other Changes"
+
+// changelogSections are the sections of a changelog in order, titled as
+// semantic-release does. The user facing ones come first.
+var changelogSections = []struct {
+	Type       string
+	Title      string
+	UserFacing bool
+}{
+	{"feat", "Features", true},
+	{"fix", "Bug Fixes", true},
+	{"perf", "Performance Improvements", true},
+	{"revert", "Reverts", true},
+	{"refactor", "Code Refactoring", false},
+	{"docs", "Documentation", false},
+	{"style", "Styles", false},
+	{"test", "Tests", false},
+	{"build", "Build System", false},
+	{"ci", "Continuous Integration", false},
+	{"chore", "Chores", false},
+}
+
+// ParseConventionalCommit parses the message of a commit.
+func ParseConventionalCommit(hash, message string) ConventionalCommit {
+	title, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	c := ConventionalCommit{
+		Hash:        hash,
+		Description: strings.TrimSpace(title),
+		Body:        strings.TrimSpace(body),
+	}
+
+	match := conventionalTitle.FindStringSubmatch(c.Description)
+	if match == nil {
+		return c
+	}
+	c.Type = strings.ToLower(match[1])
+	c.Scope = match[2]
+	c.Breaking = match[3] == "!"
+	c.Description = match[4]
+
+	if loc := breakingFooter.FindStringIndex(c.Body); loc != nil {
+		c.Breaking = true
+		note := c.Body[loc[1]:]
+		// The note ends at the next paragraph
+		note, _, _ = strings.Cut(note, "\n\n")
+		c.BreakingNote = strings.TrimSpace(note)
+	}
+	return c
+}
+
+// GroupCommits sorts commits into changelog sections, keeping their order
+// within a section. Breaking changes come last, as semantic-release puts its
+// notes below the sections, and are also listed under their type. Unless all
+// is set, only the sections users care about are kept.
+func GroupCommits(commits []ConventionalCommit, all bool) []ChangelogGroup {
+	groups := make([]ChangelogGroup, 0)
+
+	known := make(map[string]bool, len(changelogSections))
+	for _, section := range changelogSections {
+		known[section.Type] = true
+		if !section.UserFacing && !all {
+			continue
+		}
+
+		group := ChangelogGroup{Title: section.Title}
+		for _, c := range commits {
+			if c.Type == section.Type {
+				group.Commits = append(group.Commits, c)
+			}
+		}
+		if len(group.Commits) > 0 {
+			groups = append(groups, group)
+		}
+	}
+
+	if all {
+		other := ChangelogGroup{Title: otherChangesTitle}
+		for _, c := range commits {
+			if !known[c.Type] {
+				other.Commits = append(other.Commits, c)
+			}
+		}
+		if len(other.Commits) > 0 {
+			groups = append(groups, other)
+		}
+	}
+
+	breaking := make([]ConventionalCommit, 0)
+	for _, c := range commits {
+		if c.Breaking {
+			breaking = append(breaking, c)
+		}
+	}
+	if len(breaking) > 0 {
+		groups = append(groups, ChangelogGroup{Title: BreakingChangesTitle, Commits: breaking})
+	}
+	return groups
+}
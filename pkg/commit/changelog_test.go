@@ -0,0 +1,139 @@
+package commit
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseConventionalCommit(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    ConventionalCommit
+	}{
+		{
+			name:    "type only",
+			message: "fix: handle empty diffs",
+			want:    ConventionalCommit{Type: "fix", Description: "handle empty diffs"},
+		},
+		{
+			name:    "scope and body",
+			message: "feat(cli): add the changelog command\n\nIt groups the commits.\n",
+			want:    ConventionalCommit{Type: "feat", Scope: "cli", Description: "add the changelog command", Body: "It groups the commits."},
+		},
+		{
+			name:    "upper case type",
+			message: "Fix: handle empty diffs",
+			want:    ConventionalCommit{Type: "fix", Description: "handle empty diffs"},
+		},
+		{
+			name:    "breaking marker",
+			message: "feat(api)!: drop the v1 endpoints",
+			want:    ConventionalCommit{Type: "feat", Scope: "api", Description: "drop the v1 endpoints", Breaking: true},
+		},
+		{
+			name:    "breaking marker and footer",
+			message: "feat(x)!: rename the config\n\nMore context.\n\nBREAKING CHANGE: the config moved to config.json\nkeep settings.json around\n\nRefs: #12",
+			want: ConventionalCommit{
+				Type:         "feat",
+				Scope:        "x",
+				Description:  "rename the config",
+				Body:         "More context.\n\nBREAKING CHANGE: the config moved to config.json\nkeep settings.json around\n\nRefs: #12",
+				Breaking:     true,
+				BreakingNote: "the config moved to config.json\nkeep settings.json around",
+			},
+		},
+		{
+			name:    "breaking footer with hyphen",
+			message: "refactor: split the client\n\nBREAKING-CHANGE: NewClient takes options",
+			want: ConventionalCommit{
+				Type:         "refactor",
+				Description:  "split the client",
+				Body:         "BREAKING-CHANGE: NewClient takes options",
+				Breaking:     true,
+				BreakingNote: "NewClient takes options",
+			},
+		},
+		{
+			name:    "not conventional",
+			message: "Update README.md\n\nBREAKING CHANGE: ignored without a type",
+			want:    ConventionalCommit{Description: "Update README.md", Body: "BREAKING CHANGE: ignored without a type"},
+		},
+		{
+			name:    "missing space after colon",
+			message: "fix:typo",
+			want:    ConventionalCommit{Description: "fix:typo"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.Hash = "abc"
+			if got := ParseConventionalCommit("abc", tt.message); got != tt.want {
+				t.Errorf("ParseConventionalCommit() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupCommits(t *testing.T) {
+	commits := []ConventionalCommit{
+		ParseConventionalCommit("1", "feat(x)!: rename the config\n\nBREAKING CHANGE: the config moved"),
+		ParseConventionalCommit("2", "fix: handle empty diffs"),
+		ParseConventionalCommit("3", "chore: bump dependencies"),
+		ParseConventionalCommit("4", "feat: add the changelog command"),
+		ParseConventionalCommit("5", "Update README.md"),
+		ParseConventionalCommit("6", "wip: half done"),
+	}
+
+	tests := []struct {
+		name string
+		all  bool
+		want map[string][]string
+		// order lists the titles of the sections in order
+		order []string
+	}{
+		{
+			name: "user facing",
+			want: map[string][]string{
+				BreakingChangesTitle: {"1"},
+				"Features":           {"1", "4"},
+				"Bug Fixes":          {"2"},
+			},
+			order: []string{"Features", "Bug Fixes", BreakingChangesTitle},
+		},
+		{
+			name: "all",
+			all:  true,
+			want: map[string][]string{
+				BreakingChangesTitle: {"1"},
+				"Features":           {"1", "4"},
+				"Bug Fixes":          {"2"},
+				"Chores":             {"3"},
+				otherChangesTitle:    {"5", "6"},
+			},
+			order: []string{"Features", "Bug Fixes", "Chores", otherChangesTitle, BreakingChangesTitle},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := GroupCommits(commits, tt.all)
+
+			titles := make([]string, 0, len(groups))
+			for _, group := range groups {
+				titles = append(titles, group.Title)
+				hashes := make([]string, 0, len(group.Commits))
+				for _, c := range group.Commits {
+					hashes = append(hashes, c.Hash)
+				}
+				if !slices.Equal(hashes, tt.want[group.Title]) {
+					t.Errorf("section %s = %q, want %q", group.Title, hashes, tt.want[group.Title])
+				}
+			}
+			if !slices.Equal(titles, tt.order) {
+				t.Errorf("GroupCommits() sections = %q, want %q", titles, tt.order)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package commit
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mr687/lazycopilot/pkg/tokenizer"
+)
+
+// minTruncatedTokens is the smallest budget worth spending on a partial file
+// diff; below it the file is omitted instead.
+const minTruncatedTokens = 200
+
+// lowPriorityFiles are dropped first when a diff does not fit, since their
+// changes say little about the intent of a commit.
+var lowPriorityFiles = []string{
+	"go.sum",
+	"package-lock.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"Cargo.lock",
+	"composer.lock",
+	"poetry.lock",
+	"Gemfile.lock",
+}
+
+var lowPriorityDirs = []string{"vendor/", "node_modules/", "dist/"}
+
+// FileDiff is the part of a unified diff that belongs to a single file.
+type FileDiff struct {
+	Path    string
+	Content string
+}
+
+// FitResult describes a diff trimmed down to a token budget.
+type FitResult struct {
+	Diff      string
+	Tokens    int
+	Omitted   []string
+	Truncated []string
+}
+
+// SplitDiff splits the output of git diff into one part per file.
+func SplitDiff(diff string) []FileDiff {
+	files := make([]FileDiff, 0)
+	var current *FileDiff
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			if current != nil {
+				files = append(files, *current)
+			}
+			current = &FileDiff{Path: parseDiffPath(line)}
+		}
+		if current == nil {
+			continue
+		}
+		current.Content += line
+	}
+	if current != nil {
+		files = append(files, *current)
+	}
+
+	for i := range files {
+		files[i].Content = strings.TrimRight(files[i].Content, "\n")
+	}
+	return files
+}
+
+func parseDiffPath(header string) string {
+	header = strings.TrimSpace(header)
+	if i := strings.LastIndex(header, " b/"); i != -1 {
+		return header[i+3:]
+	}
+	return strings.TrimPrefix(header, "diff --git ")
+}
+
+func isLowPriority(file FileDiff) bool {
+	for _, name := range lowPriorityFiles {
+		if path.Base(file.Path) == name {
+			return true
+		}
+	}
+	for _, dir := range lowPriorityDirs {
+		if strings.HasPrefix(file.Path, dir) || strings.Contains(file.Path, "/"+dir) {
+			return true
+		}
+	}
+	if strings.HasSuffix(file.Path, ".min.js") || strings.HasSuffix(file.Path, ".min.css") || strings.HasSuffix(file.Path, ".map") {
+		return true
+	}
+	return strings.Contains(file.Content, "\nBinary files ")
+}
+
+func omittedStub(file FileDiff) string {
+	return fmt.Sprintf("diff --git a/%s b/%s\n[diff omitted to fit the prompt]", file.Path, file.Path)
+}
+
+// FitDiff trims diff to at most budget tokens. Small files are kept whole,
+// lock files, vendored and generated files are dropped first and the
+// largest remaining files are truncated or replaced by a short note.
+func FitDiff(diff string, budget int, tok *tokenizer.Tokenizer) FitResult {
+	total := tok.Count(diff)
+	if total <= budget {
+		return FitResult{Diff: diff, Tokens: total}
+	}
+
+	files := SplitDiff(diff)
+	if len(files) == 0 {
+		truncated := tok.Truncate(diff, budget)
+		return FitResult{Diff: truncated, Tokens: tok.Count(truncated)}
+	}
+
+	tokens := make([]int, len(files))
+	stubs := make([]int, len(files))
+	reserved := 0
+	for i, file := range files {
+		tokens[i] = tok.Count(file.Content)
+		stubs[i] = tok.Count(omittedStub(file))
+		reserved += stubs[i]
+	}
+
+	// Without room for a note per file, omitted files are left out silently
+	withStubs := reserved < budget
+	remaining := budget
+	if withStubs {
+		remaining -= reserved
+	} else {
+		for i := range stubs {
+			stubs[i] = 0
+		}
+	}
+
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		lowA, lowB := isLowPriority(files[order[a]]), isLowPriority(files[order[b]])
+		if lowA != lowB {
+			return !lowA
+		}
+		return tokens[order[a]] < tokens[order[b]]
+	})
+
+	parts := make([]string, len(files))
+	result := FitResult{}
+	for _, i := range order {
+		file := files[i]
+		cost := tokens[i] - stubs[i]
+		switch {
+		case cost <= remaining:
+			parts[i] = file.Content
+			remaining -= cost
+		case !isLowPriority(file) && remaining+stubs[i] >= minTruncatedTokens:
+			parts[i] = tok.Truncate(file.Content, remaining+stubs[i]-10) + "\n[diff truncated]"
+			remaining = 0
+			result.Truncated = append(result.Truncated, file.Path)
+		default:
+			if withStubs {
+				parts[i] = omittedStub(file)
+			}
+			result.Omitted = append(result.Omitted, file.Path)
+		}
+	}
+
+	kept := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	result.Diff = strings.Join(kept, "\n")
+	result.Tokens = tok.Count(result.Diff)
+	return result
+}
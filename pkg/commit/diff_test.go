@@ -0,0 +1,201 @@
+package commit
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mr687/lazycopilot/pkg/tokenizer"
+)
+
+// fileDiff returns the diff of a file with the given number of added lines.
+func fileDiff(path string, lines int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n@@ -0,0 +1,%d @@", path, path, path, path, lines)
+	for i := range lines {
+		fmt.Fprintf(&b, "\n+line %d of %s", i, path)
+	}
+	return b.String()
+}
+
+func newTestTokenizer(t *testing.T) *tokenizer.Tokenizer {
+	t.Helper()
+	tok, err := tokenizer.New(tokenizer.O200K)
+	if err != nil {
+		t.Fatalf("tokenizer.New() error = %v", err)
+	}
+	return tok
+}
+
+func TestSplitDiff(t *testing.T) {
+	tests := []struct {
+		name  string
+		diff  string
+		paths []string
+	}{
+		{"empty", "", nil},
+		{"no header", "just some text", nil},
+		{"one file", fileDiff("a.go", 2), []string{"a.go"}},
+		{"two files", fileDiff("a.go", 2) + "\n" + fileDiff("dir/b.go", 1) + "\n", []string{"a.go", "dir/b.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := SplitDiff(tt.diff)
+			paths := make([]string, 0, len(files))
+			for _, file := range files {
+				paths = append(paths, file.Path)
+				if !strings.HasPrefix(file.Content, "diff --git ") || strings.HasSuffix(file.Content, "\n") {
+					t.Errorf("content of %s = %q, want it to start at its header without a trailing newline", file.Path, file.Content)
+				}
+			}
+			if !slices.Equal(paths, tt.paths) {
+				t.Errorf("SplitDiff() paths = %q, want %q", paths, tt.paths)
+			}
+		})
+	}
+}
+
+func TestFitDiff(t *testing.T) {
+	tok := newTestTokenizer(t)
+	small := fileDiff("main.go", 3)
+	large := fileDiff("server.go", 400)
+	lock := fileDiff("go.sum", 400)
+
+	tests := []struct {
+		name          string
+		diff          string
+		budget        int
+		wantTruncated []string
+		wantOmitted   []string
+		// wantKept are contained in the result as they are
+		wantKept []string
+	}{
+		{
+			name:     "fits",
+			diff:     small + "\n" + large,
+			budget:   100000,
+			wantKept: []string{small, large},
+		},
+		{
+			name:          "truncates the largest file",
+			diff:          small + "\n" + large,
+			budget:        1000,
+			wantTruncated: []string{"server.go"},
+			wantKept:      []string{small},
+		},
+		{
+			name:          "omits low priority files first",
+			diff:          small + "\n" + lock + "\n" + large,
+			budget:        1000,
+			wantTruncated: []string{"server.go"},
+			wantOmitted:   []string{"go.sum"},
+			wantKept:      []string{small, "diff --git a/go.sum b/go.sum\n[diff omitted to fit the prompt]"},
+		},
+		{
+			name:        "budget smaller than one file header",
+			diff:        small + "\n" + large,
+			budget:      5,
+			wantOmitted: []string{"main.go", "server.go"},
+		},
+		{
+			name:   "no file headers",
+			diff:   strings.Repeat("some words ", 500),
+			budget: 50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FitDiff(tt.diff, tt.budget, tok)
+
+			if result.Tokens > tt.budget {
+				t.Errorf("FitDiff() is %d tokens, want at most %d", result.Tokens, tt.budget)
+			}
+			if result.Tokens != tok.Count(result.Diff) {
+				t.Errorf("FitDiff() reports %d tokens, the diff has %d", result.Tokens, tok.Count(result.Diff))
+			}
+			if !slices.Equal(result.Truncated, tt.wantTruncated) {
+				t.Errorf("FitDiff() truncated %q, want %q", result.Truncated, tt.wantTruncated)
+			}
+			slices.Sort(result.Omitted)
+			if !slices.Equal(result.Omitted, tt.wantOmitted) {
+				t.Errorf("FitDiff() omitted %q, want %q", result.Omitted, tt.wantOmitted)
+			}
+			for _, kept := range tt.wantKept {
+				if !strings.Contains(result.Diff, kept) {
+					t.Errorf("FitDiff() = %q, want it to contain %q", result.Diff, kept)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkDiff(t *testing.T) {
+	tok := newTestTokenizer(t)
+	a := fileDiff("a.go", 20)
+	b := fileDiff("b.go", 20)
+	c := fileDiff("c.go", 20)
+	large := fileDiff("large.go", 400)
+
+	tests := []struct {
+		name    string
+		diff    string
+		budget  int
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "one chunk",
+			diff:   a + "\n" + b + "\n" + c,
+			budget: 100000,
+			want:   []string{a + "\n" + b + "\n" + c},
+		},
+		{
+			name:   "one file per chunk",
+			diff:   a + "\n" + b + "\n" + c,
+			budget: tok.Count(a) + tok.Count(b) - 1,
+			want:   []string{a, b, c},
+		},
+		{
+			name:   "large file in a chunk of its own",
+			diff:   a + "\n" + large + "\n" + b,
+			budget: 1000,
+			want:   []string{a, "", b},
+		},
+		{
+			name:    "budget smaller than one file header",
+			diff:    a,
+			budget:  5,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := ChunkDiff(tt.diff, tt.budget, tok)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ChunkDiff() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(chunks) != len(tt.want) {
+				t.Fatalf("ChunkDiff() = %d chunks, want %d", len(chunks), len(tt.want))
+			}
+			for i, chunk := range chunks {
+				if tokens := tok.Count(chunk); tokens > tt.budget {
+					t.Errorf("chunk %d is %d tokens, want at most %d", i, tokens, tt.budget)
+				}
+				// An empty want stands for a truncated file
+				if tt.want[i] == "" {
+					if !strings.HasSuffix(chunk, "[diff truncated]") {
+						t.Errorf("chunk %d = %q, want it truncated", i, chunk)
+					}
+					continue
+				}
+				if chunk != tt.want[i] {
+					t.Errorf("chunk %d = %q, want %q", i, chunk, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package commit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/copilot"
+	"github.com/mr687/lazycopilot/pkg/tokenizer"
+)
+
+// ChunkDiff groups the files of a diff into chunks of at most budget tokens,
+// keeping their order. A file larger than the budget is truncated into a
+// chunk of its own, which needs a budget of at least minTruncatedTokens.
+func ChunkDiff(diff string, budget int, tok *tokenizer.Tokenizer) ([]string, error) {
+	if budget < minTruncatedTokens {
+		return nil, fmt.Errorf("a budget of %d tokens is too small for a part of the diff, %d are needed", budget, minTruncatedTokens)
+	}
+
+	chunks := make([]string, 0)
+	current := make([]string, 0)
+	currentTokens := 0
+
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, "\n"))
+			current = current[:0]
+			currentTokens = 0
+		}
+	}
+
+	for _, file := range SplitDiff(diff) {
+		tokens := tok.Count(file.Content)
+		if tokens > budget {
+			flush()
+			chunks = append(chunks, tok.Truncate(file.Content, budget-10)+"\n[diff truncated]")
+			continue
+		}
+		if currentTokens+tokens > budget {
+			flush()
+		}
+		current = append(current, file.Content)
+		currentTokens += tokens
+	}
+	flush()
+
+	return chunks, nil
+}
+
+// SummarizeChunks asks the client to summarize every chunk of a diff, with at
+// most parallelism requests in flight. The summaries keep the order of the
+// chunks and the first error cancels the remaining requests.
+func SummarizeChunks(ctx context.Context, client copilot.Copilot, chunks []string, opts copilot.AskOptions, parallelism int) ([]string, error) {
+	opts.DisableTools = true
+
+	summaries := make([]string, len(chunks))
+	if len(chunks) == 0 {
+		return summaries, nil
+	}
+
+	summarize := func(ctx context.Context, i int) error {
+		prompt := strings.ReplaceAll(config.DIFF_SUMMARY_PROMPT, "{{diff}}", chunks[i])
+		// Every chunk gets a conversation of its own to keep the summaries
+		// independent of each other
+		summary, err := client.NewConversation().Ask(ctx, prompt, &opts)
+		if err != nil {
+			return err
+		}
+		summaries[i] = summary
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, max(parallelism, 1))
+	for i := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := summarize(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return summaries, nil
+}
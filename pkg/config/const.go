@@ -1,7 +1,19 @@
 package config
 
 const (
-	APP_DIR_NAME      = "lazycopilot"
-	STYLES_FILE_NAME  = "commit-styles.json"
-	DEFAULT_APP_PATHS = "/.config"
+	APP_DIR_NAME       = "lazycopilot"
+	STYLES_FILE_NAME   = "commit-styles.json"
+	SETTINGS_FILE_NAME = "config.json"
+	DEFAULT_APP_PATHS  = "/.config"
+	API_URL_ENV        = "LAZYCOPILOT_API_URL"
+	TOKEN_URL_ENV      = "LAZYCOPILOT_TOKEN_URL"
+	PROVIDER_ENV       = "LAZYCOPILOT_PROVIDER"
+	API_KEY_ENV        = "LAZYCOPILOT_API_KEY"
+
+	PROVIDER_COPILOT = "copilot"
+	PROVIDER_OPENAI  = "openai"
+
+	// DEFAULT_BRANCH_PATTERN follows the type/TICKET-short-slug convention,
+	// the ticket being optional.
+	DEFAULT_BRANCH_PATTERN = `^(feat|fix|docs|style|refactor|perf|test|build|ci|chore|revert)/([A-Z][A-Z0-9]*-[0-9]+-)?[a-z0-9]+(-[a-z0-9]+)*$`
 )
@@ -4,4 +4,44 @@ func wrapBlockCode(t string, code string) string {
 	return "```" + t + "\n" + code + "\n```"
 }
 
-var COMMIT_PROMPT = wrapBlockCode("diff", "{{diff}}") + "\n\n" + "Write a concise and informative commit message for the change with commitizen convention. If multiple files are changed, provide a summary of the changes without being too specific per-file changes. Ensure the message is readable and clearly conveys the purpose of the changes. Make sure the title has maximum 50 characters and message is wrapped at 72 characters. DON'T WRAP IN CODE BLOCK."
+var COMMIT_INSTRUCTIONS = "Write a concise and informative commit message for the change with commitizen convention. If multiple files are changed, provide a summary of the changes without being too specific per-file changes. Ensure the message is readable and clearly conveys the purpose of the changes. Make sure the title has maximum 50 characters and message is wrapped at 72 characters. DON'T WRAP IN CODE BLOCK."
+
+var COMMIT_PROMPT = wrapBlockCode("diff", "{{diff}}") + "\n\n" + COMMIT_INSTRUCTIONS
+
+var DIFF_SUMMARY_PROMPT = wrapBlockCode("diff", "{{diff}}") + "\n\n" + "Summarize the changes in this part of a larger diff as a short bullet list. Mention the affected files and focus on what changed and why, not on line-by-line details. Don't write a commit message."
+
+var COMMIT_SUMMARIES_PROMPT = "The change is too large to show in full. These are summaries of its parts:\n\n{{summaries}}\n\n" + COMMIT_INSTRUCTIONS
+
+var SQUASH_INSTRUCTIONS = "Write a single commit message that squashes these commits into one, following the Conventional Commits specification: a title of the form `type(scope): description` with a maximum of 50 characters, a blank line and a body wrapped at 72 characters that summarizes the overall change. Use the commit messages to understand the intent, but describe the final result rather than listing every commit. DON'T WRAP IN CODE BLOCK."
+
+var SQUASH_PROMPT = "These are the commits being squashed:\n\n{{commits}}\n\nTheir combined changes:\n\n" + wrapBlockCode("diff", "{{diff}}") + "\n\n" + SQUASH_INSTRUCTIONS
+
+var SQUASH_SUMMARIES_PROMPT = "These are the commits being squashed:\n\n{{commits}}\n\nTheir combined changes are too large to show in full. These are summaries of its parts:\n\n{{summaries}}\n\n" + SQUASH_INSTRUCTIONS
+
+var PR_INSTRUCTIONS = "Write a pull request description for these changes. Put a concise title of at most 72 characters on the first line, without a prefix such as \"Title:\" or markdown, then a blank line and a Markdown body that explains what changed and why, how it was verified if that can be inferred, and anything reviewers should pay attention to. Describe the overall change rather than listing every commit."
+
+var PR_TEMPLATE_INSTRUCTIONS = "The body must follow this pull request template. Keep its headings in order and fill in every section from the changes. Leave checklists unchecked and replace HTML comments with content:\n\n" + wrapBlockCode("markdown", "{{template}}")
+
+var PR_PROMPT = "Commits:\n\n{{commits}}\n\nDiff stat:\n\n" + wrapBlockCode("", "{{stat}}") + "\n\nChanges:\n\n" + wrapBlockCode("diff", "{{diff}}") + "\n\n" + PR_INSTRUCTIONS
+
+var PR_SUMMARIES_PROMPT = "Commits:\n\n{{commits}}\n\nDiff stat:\n\n" + wrapBlockCode("", "{{stat}}") + "\n\nThe changes are too large to show in full. These are summaries of their parts:\n\n{{summaries}}\n\n" + PR_INSTRUCTIONS
+
+var CHANGELOG_INSTRUCTIONS = "Write the release notes for these commits in Markdown. Use the given sections in the same order with the same `### ` headings and leave out sections without entries. Write every entry as a `* ` bullet that starts with the scope in bold, as in `**scope:** `, when the commit has one and ends with the commit reference exactly as given. Merge commits that belong to the same change into one entry that keeps all their references. Don't add a title or a version heading. DON'T WRAP IN CODE BLOCK."
+
+var CHANGELOG_USERS_INSTRUCTIONS = "The notes are for the users of the project. Describe what changed for them in plain language, explain how to adapt to breaking changes, and leave out internal details such as refactorings, tests or build changes."
+
+var CHANGELOG_DEVELOPERS_INSTRUCTIONS = "The notes are for developers who work on or integrate with the project. Keep technical details such as the affected packages, APIs and configuration, and mention internal changes that matter to contributors."
+
+var CHANGELOG_PROMPT = "These are the commits of the release grouped by section:\n\n{{commits}}\n\n" + CHANGELOG_INSTRUCTIONS + " {{audience}}"
+
+var BRANCH_INSTRUCTIONS = "Suggest names for a git branch holding this work. The names follow the convention `type/TICKET-short-slug`: a Conventional Commits type such as feat or fix, a slash, {{ticket}} and a slug of two to five lowercase words joined by hyphens. Every name must match the regular expression `{{pattern}}`. Write {{count}} of them, one per line, without numbering, quotes or explanations."
+
+var BRANCH_DIFF_PROMPT = wrapBlockCode("diff", "{{diff}}") + "\n\n" + BRANCH_INSTRUCTIONS
+
+var BRANCH_TASK_PROMPT = "The task to work on:\n\n{{task}}\n\n" + BRANCH_INSTRUCTIONS
+
+var STAGED_DIFF_PROMPT = "These are my staged changes:\n\n" + wrapBlockCode("diff", "{{diff}}")
+
+var STDIN_PROMPT = wrapBlockCode("", "{{input}}")
+
+var RAW_OUTPUT_INSTRUCTIONS = "Respond with plain text only. Don't use markdown formatting such as headings, bold text or lists, and don't wrap code in code blocks."
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mr687/lazycopilot/pkg/utils"
+)
+
+type Settings struct {
+	// Provider selects the API answering requests, PROVIDER_COPILOT by
+	// default. PROVIDER_ENV takes precedence over it.
+	Provider     string `json:"provider,omitempty"`
+	DefaultModel string `json:"default_model,omitempty"`
+	// APIURL and TokenURL override the Copilot API base URL and the token
+	// exchange URL. The API_URL_ENV and TOKEN_URL_ENV variables take
+	// precedence over both.
+	APIURL   string `json:"api_url,omitempty"`
+	TokenURL string `json:"token_url,omitempty"`
+	// OpenAI configures the PROVIDER_OPENAI provider.
+	OpenAI *OpenAISettings `json:"openai,omitempty"`
+	// BranchPattern is the regular expression suggested branch names must
+	// match, DEFAULT_BRANCH_PATTERN by default.
+	BranchPattern string `json:"branch_pattern,omitempty"`
+}
+
+// OpenAISettings point lazycopilot at an OpenAI compatible chat completions
+// API, e.g. a llama.cpp or vLLM server.
+type OpenAISettings struct {
+	// BaseURL is the URL the /chat/completions and /models paths are
+	// appended to, e.g. http://localhost:8080/v1.
+	BaseURL string `json:"base_url"`
+	// APIKey is sent as bearer token. API_KEY_ENV takes precedence over it.
+	APIKey string `json:"api_key,omitempty"`
+	// Model is the default model of the provider.
+	Model string `json:"model,omitempty"`
+	// MaxPromptTokens and MaxOutputTokens are the limits of the models,
+	// which the models endpoint doesn't report.
+	MaxPromptTokens int `json:"max_prompt_tokens,omitempty"`
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
+	// ToolCalls offers tools to the models, which not every server accepts.
+	ToolCalls bool `json:"tool_calls,omitempty"`
+}
+
+func (s *OpenAISettings) GetAPIKey() string {
+	if key := os.Getenv(API_KEY_ENV); key != "" {
+		return key
+	}
+	return s.APIKey
+}
+
+func (s *Settings) GetProvider() string {
+	if provider := os.Getenv(PROVIDER_ENV); provider != "" {
+		return provider
+	}
+	if s.Provider != "" {
+		return s.Provider
+	}
+	return PROVIDER_COPILOT
+}
+
+// GetDefaultModel returns the default model configured for the selected
+// provider.
+func (s *Settings) GetDefaultModel() string {
+	if s.GetProvider() == PROVIDER_OPENAI {
+		if s.OpenAI == nil {
+			return ""
+		}
+		return s.OpenAI.Model
+	}
+	return s.DefaultModel
+}
+
+// SetDefaultModel sets the default model of the selected provider.
+func (s *Settings) SetDefaultModel(model string) {
+	if s.GetProvider() == PROVIDER_OPENAI {
+		if s.OpenAI == nil {
+			s.OpenAI = &OpenAISettings{}
+		}
+		s.OpenAI.Model = model
+		return
+	}
+	s.DefaultModel = model
+}
+
+func (s *Settings) GetBranchPattern() string {
+	if s.BranchPattern != "" {
+		return s.BranchPattern
+	}
+	return DEFAULT_BRANCH_PATTERN
+}
+
+func (s *Settings) GetAPIURL() string {
+	if url := os.Getenv(API_URL_ENV); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return strings.TrimSuffix(s.APIURL, "/")
+}
+
+func (s *Settings) GetTokenURL() string {
+	if url := os.Getenv(TOKEN_URL_ENV); url != "" {
+		return url
+	}
+	return s.TokenURL
+}
+
+func GetSettingsConfigPath() string {
+	configDir := utils.GetConfigPath()
+	if configDir == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return ""
+		}
+		configDir = filepath.Join(home, DEFAULT_APP_PATHS)
+	}
+	return filepath.Join(configDir, APP_DIR_NAME, SETTINGS_FILE_NAME)
+}
+
+func LoadSettings() *Settings {
+	settings := &Settings{}
+	configPath := GetSettingsConfigPath()
+	if configPath == "" {
+		return settings
+	}
+
+	// A missing or broken file simply means nothing was configured yet
+	_ = utils.LoadFileJson(configPath, settings)
+	return settings
+}
+
+func SaveSettings(settings *Settings) error {
+	configPath := GetSettingsConfigPath()
+	if configPath == "" {
+		return nil
+	}
+
+	return utils.SaveFile(configPath, settings)
+}
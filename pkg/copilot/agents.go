@@ -0,0 +1,82 @@
+package copilot
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DefaultAgent is the synthetic agent that answers through the regular chat
+// completions endpoint.
+const DefaultAgent = "copilot"
+
+// CopilotReference is a source an agent used for its answer.
+type CopilotReference struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Metadata struct {
+		DisplayName string `json:"display_name"`
+		DisplayURL  string `json:"display_url"`
+	} `json:"metadata"`
+}
+
+// CopilotError is reported by agents inside an otherwise successful stream.
+type CopilotError struct {
+	Type       string `json:"type"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Identifier string `json:"identifier"`
+}
+
+func (e CopilotError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("agent error (%s): %s", e.Code, e.Message)
+	}
+	return "agent error: " + e.Message
+}
+
+// completionPath returns the endpoint answering for the agent. Agents other
+// than the default one have their own endpoint and always stream.
+func (c *copilot) completionPath(ctx context.Context, agent string) (string, bool, error) {
+	if agent == "" || agent == DefaultAgent {
+		return "/chat/completions", false, nil
+	}
+
+	agents, err := c.FetchAgents(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to fetch agents: %w", err)
+	}
+	if _, ok := agents[agent]; !ok {
+		return "", false, fmt.Errorf("agent %s not found", agent)
+	}
+	return "/agents/" + url.PathEscape(agent) + "?chat", true, nil
+}
+
+// formatReferences renders the references of an agent answer as a list to
+// append to its content.
+func formatReferences(references []CopilotReference) string {
+	if len(references) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n\nReferences:\n")
+	seen := make(map[string]bool)
+	for _, ref := range references {
+		name := ref.Metadata.DisplayName
+		if name == "" {
+			name = ref.ID
+		}
+		line := "- " + name
+		if ref.Metadata.DisplayURL != "" {
+			line += " (" + ref.Metadata.DisplayURL + ")"
+		}
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		b.WriteString(line + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
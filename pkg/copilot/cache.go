@@ -0,0 +1,40 @@
+package copilot
+
+import (
+	"time"
+
+	"github.com/mr687/lazycopilot/pkg/utils"
+)
+
+// cacheTTL is how long fetched models and agents are trusted before they are
+// requested again.
+const cacheTTL = 24 * time.Hour
+
+// cache is the on-disk format of models.json and agents.json.
+type cache[T any] struct {
+	FetchedAt time.Time     `json:"fetched_at"`
+	Endpoint  string        `json:"endpoint"`
+	Data      map[string]*T `json:"data"`
+}
+
+func newCache[T any]() *cache[T] {
+	return &cache[T]{Data: make(map[string]*T)}
+}
+
+// loadCache reads a cache file. Missing files and files in the old format,
+// which stored the bare map, yield an empty cache.
+func loadCache[T any](path string) *cache[T] {
+	c := newCache[T]()
+	if err := utils.LoadFileJson(path, c); err != nil || c.Data == nil {
+		return newCache[T]()
+	}
+	return c
+}
+
+func (c *cache[T]) isFresh() bool {
+	return len(c.Data) > 0 && time.Since(c.FetchedAt) < cacheTTL
+}
+
+func (c *cache[T]) save(path string) error {
+	return utils.SaveFile(path, c)
+}
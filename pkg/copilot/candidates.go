@@ -0,0 +1,130 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+func (c *copilot) askCandidates(ctx context.Context, conv *conversation, prompt string, opts *AskOptions) ([]string, error) {
+	prompt = strings.TrimSpace(prompt)
+
+	model := ""
+	if opts != nil {
+		model = opts.Model
+	}
+	modelConfig, err := c.GetModel(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+
+	askOpts := c.resolveAskOptions(opts, modelConfig)
+	path, isAgent, err := c.completionPath(ctx, askOpts.Agent)
+	if err != nil {
+		return nil, err
+	}
+	if isAgent {
+		return nil, fmt.Errorf("agent '%s' can't generate candidates", askOpts.Agent)
+	}
+
+	var histories []PromptMessage
+	if !askOpts.IgnoreHistory {
+		histories = conv.History()
+	}
+	messages := append(append([]PromptMessage(nil), histories...), PromptMessage{
+		Content: prompt,
+		Role:    userRole,
+	})
+
+	candidates := make([]string, 0, askOpts.N)
+	// The reason of the first rejected choice is reported when no candidate
+	// is left
+	var rejected error
+	collect := func(result completion) error {
+		c.recordUsage(askOpts.Model, result.Usage)
+		for _, choice := range result.Choices {
+			check := completion{
+				PromptFilterResults: result.PromptFilterResults,
+				FinishReason:        choice.FinishReason,
+				ContentFilter:       choice.ContentFilterResults,
+			}
+			err := check.filterError()
+			var filterErr *ContentFilterError
+			if errors.As(err, &filterErr) && filterErr.Prompt {
+				return err
+			}
+			if err == nil && choice.FinishReason == finishLength {
+				err = ErrTruncated
+			}
+			if err == nil && strings.TrimSpace(choice.Message.Content) == "" {
+				err = errors.New("failed to get response")
+			}
+			if err != nil {
+				if rejected == nil {
+					rejected = err
+				}
+				continue
+			}
+			if len(candidates) < askOpts.N {
+				candidates = append(candidates, strings.TrimSpace(choice.Message.Content))
+			}
+		}
+		return nil
+	}
+
+	result, err := c.complete(ctx, path, c.generateAskRequest(messages, askOpts, false, nil), false, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := collect(result); err != nil {
+		return nil, err
+	}
+
+	// Models that ignore n answer with a single choice and some choices may
+	// be rejected, the missing candidates are requested in parallel
+	if missing := askOpts.N - len(candidates); missing > 0 {
+		single := askOpts
+		single.N = 1
+		body := c.generateAskRequest(messages, single, false, nil)
+
+		results := make([]completion, missing)
+		errs := make([]error, missing)
+		var wg sync.WaitGroup
+		for i := 0; i < missing; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], errs[i] = c.complete(ctx, path, body, false, nil)
+			}(i)
+		}
+		wg.Wait()
+
+		for i := range results {
+			if errs[i] != nil {
+				return nil, errs[i]
+			}
+			if err := collect(results[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		if rejected == nil {
+			rejected = errors.New("failed to get response")
+		}
+		return nil, rejected
+	}
+	return candidates, nil
+}
+
+// Exchange returns the messages of a prompt and its answer, e.g. to add the
+// chosen candidate to the history with SetHistory.
+func Exchange(prompt, answer string) []PromptMessage {
+	return []PromptMessage{
+		{Content: strings.TrimSpace(prompt), Role: userRole},
+		{Content: answer, Role: assistantRole},
+	}
+}
@@ -0,0 +1,72 @@
+package copilot
+
+import (
+	"context"
+	"sync"
+)
+
+// Conversation is a thread of messages with the model. Every conversation
+// keeps its own history, so conversations of one client can be used from
+// different goroutines.
+type Conversation interface {
+	Ask(ctx context.Context, prompt string, opts *AskOptions) (string, error)
+	// AskStream works like Ask but streams the response when the model
+	// supports it, calling onChunk for every piece of content received.
+	AskStream(ctx context.Context, prompt string, opts *AskOptions, onChunk func(chunk string)) (string, error)
+	// AskCandidates asks for opts.N alternative answers, sending parallel
+	// requests when the model ignores n. Tools are not offered and the
+	// exchange is not added to the history since no answer is chosen yet.
+	AskCandidates(ctx context.Context, prompt string, opts *AskOptions) ([]string, error)
+
+	// History returns the messages exchanged so far and SetHistory replaces
+	// them, e.g. to continue a saved Session.
+	History() []PromptMessage
+	SetHistory(messages []PromptMessage)
+}
+
+type conversation struct {
+	client *copilot
+
+	mu       sync.Mutex
+	messages []PromptMessage
+}
+
+// NewConversation implements Copilot.
+func (c *copilot) NewConversation() Conversation {
+	return &conversation{client: c}
+}
+
+// Ask implements Conversation.
+func (v *conversation) Ask(ctx context.Context, prompt string, opts *AskOptions) (string, error) {
+	return v.client.ask(ctx, v, prompt, opts, nil)
+}
+
+// AskStream implements Conversation.
+func (v *conversation) AskStream(ctx context.Context, prompt string, opts *AskOptions, onChunk func(chunk string)) (string, error) {
+	return v.client.ask(ctx, v, prompt, opts, onChunk)
+}
+
+// AskCandidates implements Conversation.
+func (v *conversation) AskCandidates(ctx context.Context, prompt string, opts *AskOptions) ([]string, error) {
+	return v.client.askCandidates(ctx, v, prompt, opts)
+}
+
+// History implements Conversation.
+func (v *conversation) History() []PromptMessage {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]PromptMessage(nil), v.messages...)
+}
+
+// SetHistory implements Conversation.
+func (v *conversation) SetHistory(messages []PromptMessage) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.messages = append([]PromptMessage(nil), messages...)
+}
+
+func (v *conversation) append(messages ...PromptMessage) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.messages = append(v.messages, messages...)
+}
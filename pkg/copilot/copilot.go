@@ -1,20 +1,23 @@
 package copilot
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/usage"
 	"github.com/mr687/lazycopilot/pkg/utils"
 )
 
 const (
 	apiURL               = "https://api.githubcopilot.com"
+	tokenURL             = "https://api.github.com/copilot_internal/v2/token"
 	authorizationHeader  = "Authorization"
 	acceptHeader         = "Accept"
 	contentTypeHeader    = "Content-Type"
@@ -22,11 +25,19 @@ const (
 	copilotIntegrationID = "vscode-chat"
 	openaiOrganization   = "github-copilot"
 	openaiIntent         = "conversation-panel"
-	defaultModel         = "gpt-4o"
+	DefaultModel         = "gpt-4o"
 	defaultTemperature   = 0.1
+	defaultTopP          = 1.0
+	defaultN             = 1
 	defaultSystemRole    = "system"
 	userRole             = "user"
 	assistantRole        = "assistant"
+	finishLength         = "length"
+	finishContentFilter  = "content_filter"
+	// maxContinuations bounds how often a response cut off at the output
+	// limit is continued.
+	maxContinuations = 3
+	continuePrompt   = "Your response was cut off. Continue exactly where you left off, without repeating anything."
 )
 
 type GithubEndpoint struct {
@@ -37,31 +48,34 @@ type GithubEndpoint struct {
 }
 
 type GithubToken struct {
-	AnnotationsEnabled           bool           `json:"annotations_enabled"`
-	ChatEnabled                  bool           `json:"chat_enabled"`
-	ChatJetbrainsEnabled         bool           `json:"chat_jetbrains_enabled"`
-	CodeQuoteEnabled             bool           `json:"code_quote_enabled"`
-	CodeReviewEnabled            bool           `json:"code_review_enabled"`
-	Codesearch                   bool           `json:"codesearch"`
-	CopilotignoreEnabled         bool           `json:"copilotignore_enabled"`
-	Endpoints                    GithubEndpoint `json:"endpoints"`
-	ExpiresAt                    int            `json:"expires_at"`
-	Individual                   bool           `json:"individual"`
-	LimitedUserQuotas            any            `json:"limited_user_quotas"`
-	LimitedUserResetDate         any            `json:"limited_user_reset_date"`
-	NesEnabled                   bool           `json:"nes_enabled"`
-	Prompt8K                     bool           `json:"prompt_8k"`
-	PublicSuggestions            string         `json:"public_suggestions"`
-	RefreshIn                    int            `json:"refresh_in"`
-	Sku                          string         `json:"sku"`
-	SnippyLoadTestEnabled        bool           `json:"snippy_load_test_enabled"`
-	Telemetry                    string         `json:"telemetry"`
-	Token                        string         `json:"token"`
-	TrackingID                   string         `json:"tracking_id"`
-	TriggerCompletionAfterAccept bool           `json:"trigger_completion_after_accept"`
-	VscElectronFetcherV2         bool           `json:"vsc_electron_fetcher_v2"`
-	Xcode                        bool           `json:"xcode"`
-	XcodeChat                    bool           `json:"xcode_chat"`
+	AnnotationsEnabled   bool           `json:"annotations_enabled"`
+	ChatEnabled          bool           `json:"chat_enabled"`
+	ChatJetbrainsEnabled bool           `json:"chat_jetbrains_enabled"`
+	CodeQuoteEnabled     bool           `json:"code_quote_enabled"`
+	CodeReviewEnabled    bool           `json:"code_review_enabled"`
+	Codesearch           bool           `json:"codesearch"`
+	CopilotignoreEnabled bool           `json:"copilotignore_enabled"`
+	Endpoints            GithubEndpoint `json:"endpoints"`
+	ExpiresAt            int            `json:"expires_at"`
+	Individual           bool           `json:"individual"`
+	LimitedUserQuotas    any            `json:"limited_user_quotas"`
+	LimitedUserResetDate any            `json:"limited_user_reset_date"`
+	NesEnabled           bool           `json:"nes_enabled"`
+	Prompt8K             bool           `json:"prompt_8k"`
+	PublicSuggestions    string         `json:"public_suggestions"`
+	RefreshIn            int            `json:"refresh_in"`
+	// RefreshAt is when the token should be replaced, derived from
+	// RefreshIn once fetched. It isn't part of the API response.
+	RefreshAt                    int64  `json:"refresh_at,omitempty"`
+	Sku                          string `json:"sku"`
+	SnippyLoadTestEnabled        bool   `json:"snippy_load_test_enabled"`
+	Telemetry                    string `json:"telemetry"`
+	Token                        string `json:"token"`
+	TrackingID                   string `json:"tracking_id"`
+	TriggerCompletionAfterAccept bool   `json:"trigger_completion_after_accept"`
+	VscElectronFetcherV2         bool   `json:"vsc_electron_fetcher_v2"`
+	Xcode                        bool   `json:"xcode"`
+	XcodeChat                    bool   `json:"xcode_chat"`
 }
 
 type ModelCapabilityLimit struct {
@@ -83,8 +97,10 @@ type ModelCapabilities struct {
 }
 
 type PromptMessage struct {
-	Content string `json:"content"`
-	Role    string `json:"role"`
+	Content    string     `json:"content"`
+	Role       string     `json:"role"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
 }
 
 type Model struct {
@@ -113,6 +129,9 @@ type CompletionResponse struct {
 	PromptFilterResults []PromptFilterResult `json:"prompt_filter_results"`
 	SystemFingerprint   string               `json:"system_fingerprint"`
 	Usage               Usage                `json:"usage"`
+	// Agents report their sources and failures next to the choices
+	CopilotReferences []CopilotReference `json:"copilot_references"`
+	CopilotErrors     []CopilotError     `json:"copilot_errors"`
 }
 
 type Choice struct {
@@ -120,6 +139,7 @@ type Choice struct {
 	FinishReason         string               `json:"finish_reason"`
 	Index                int64                `json:"index"`
 	Message              Message              `json:"message"`
+	Delta                Message              `json:"delta"`
 }
 
 type ContentFilterResults struct {
@@ -135,8 +155,9 @@ type Hate struct {
 }
 
 type Message struct {
-	Content string `json:"content"`
-	Role    string `json:"role"`
+	Content   string     `json:"content"`
+	Role      string     `json:"role"`
+	ToolCalls []ToolCall `json:"tool_calls"`
 }
 
 type PromptFilterResult struct {
@@ -160,49 +181,145 @@ type PromptTokensDetails struct {
 	CachedTokens int64 `json:"cached_tokens"`
 }
 
+// AskOptions configures a single request. Zero values fall back to the
+// client defaults.
+type AskOptions struct {
+	// Model is the model id, defaults to the configured default model.
+	Model string
+	// SystemPrompt defaults to COPILOT_INSTRUCTIONS.
+	SystemPrompt string
+	// Temperature and TopP are ignored by o1 models.
+	Temperature *float64
+	TopP        *float64
+	// MaxOutputTokens defaults to the output limit of the model.
+	MaxOutputTokens int
+	// N is the number of choices to generate, defaults to 1.
+	N int
+	// IgnoreHistory skips sending previous messages along with the prompt.
+	IgnoreHistory bool
+	// NoAppendHistory keeps the prompt and response out of the history.
+	NoAppendHistory bool
+	// DisableTools doesn't offer the registered tools to the model.
+	DisableTools bool
+	// Agent routes the conversation to the agent with this slug instead of
+	// the model directly. Agents don't get the registered tools.
+	Agent string
+}
+
 type Copilot interface {
+	// FetchModels and FetchAgents return the cached lists while they are
+	// fresh and request them again once they expire.
 	FetchModels(ctx context.Context) (map[string]*Model, error)
 	FetchAgents(ctx context.Context) (map[string]*Agent, error)
-
-	Ask(ctx context.Context, prompt string, opts any) (string, error)
+	// GetModel looks up a model by id, refreshing the cache when it is
+	// missing. An empty id returns the default model.
+	GetModel(ctx context.Context, id string) (*Model, error)
+	// RefreshModels and RefreshAgents bypass the cache.
+	RefreshModels(ctx context.Context) (map[string]*Model, error)
+	RefreshAgents(ctx context.Context) (map[string]*Agent, error)
+
+	// RegisterTool declares a function the model may call while answering.
+	// Tools are offered to models that support tool calls, their results
+	// are sent back until the model gives a final answer.
+	RegisterTool(tool Tool) error
+
+	// RefreshInBackground renews the credentials of the provider ahead of
+	// their expiry until ctx is done, for long-lived sessions such as chat.
+	RefreshInBackground(ctx context.Context)
+
+	// NewConversation starts a conversation with an empty history that
+	// shares the models, tools and token of the client.
+	NewConversation() Conversation
+	// Conversation is the default conversation of the client, for callers
+	// that need only one.
+	Conversation
 }
 
+// NewCopilot returns a client for the provider selected in the settings.
 func NewCopilot() Copilot {
+	settings := config.LoadSettings()
+	provider, err := NewProvider(settings)
+	if err != nil {
+		provider = &unavailableProvider{name: settings.GetProvider(), err: err}
+	}
+
+	defaultModel := settings.GetDefaultModel()
+	if defaultModel == "" && provider.Name() == config.PROVIDER_COPILOT {
+		defaultModel = DefaultModel
+	}
+	return NewClient(provider, defaultModel)
+}
+
+// NewClient returns a client for provider. An empty defaultModel picks the
+// only model of the provider, if it has just one.
+func NewClient(provider Provider, defaultModel string) Copilot {
 	c := &copilot{
-		models:    make(map[string]*Model),
-		agents:    make(map[string]*Agent),
-		machineId: utils.GenerateMachineId(),
+		provider:     provider,
+		tools:        make(map[string]*Tool),
+		defaultModel: defaultModel,
 	}
+	c.conversation = &conversation{client: c}
 
-	configPath := utils.GetConfigPath() + "/lazycopilot"
-	c.githubToken = c.getCachedToken()
-	_ = utils.LoadFileJson(configPath+"/token.json", &c.token)
-	_ = utils.LoadFileJson(configPath+"/models.json", &c.models)
-	_ = utils.LoadFileJson(configPath+"/agents.json", &c.agents)
+	c.models = loadCache[Model](c.cachePath("models"))
+	c.agents = loadCache[Agent](c.cachePath("agents"))
 
 	return c
 }
 
+// copilot is safe for concurrent use. Concurrent model or agent fetches are
+// merged into a single request.
 type copilot struct {
-	machineId   string
-	sessionId   string
-	token       *GithubToken
-	agents      map[string]*Agent
-	models      map[string]*Model
-	histories   []PromptMessage
-	githubToken *string
+	provider     Provider
+	defaultModel string
+	conversation *conversation
+
+	// mu guards the fields below
+	mu     sync.RWMutex
+	agents *cache[Agent]
+	models *cache[Model]
+	tools  map[string]*Tool
+
+	modelsFlight flight[map[string]*Model]
+	agentsFlight flight[map[string]*Agent]
+}
+
+// RefreshInBackground implements Copilot.
+func (c *copilot) RefreshInBackground(ctx context.Context) {
+	if refresher, ok := c.provider.(BackgroundRefresher); ok {
+		refresher.RefreshInBackground(ctx)
+	}
 }
 
-func (c *copilot) generateAskRequest(histories []PromptMessage, prompt, systemPrompt, model string, temperature float64, maxOutputToken int, stream bool) interface{} {
-	isO1 := strings.HasPrefix(model, "o1")
+// cachePath returns the path of a cache file. APIs other than Copilot get
+// files of their own, since they list different models.
+func (c *copilot) cachePath(name string) string {
+	if key := c.provider.CacheKey(); key != "" {
+		name += "." + key
+	}
+	return utils.GetConfigPath() + "/lazycopilot/" + name + ".json"
+}
+
+// IsReasoningModel reports whether the model belongs to the o-series, which
+// rejects the system role and sampling parameters.
+func IsReasoningModel(model string) bool {
+	for _, prefix := range []string{"o1", "o3", "o4"} {
+		if model == prefix || strings.HasPrefix(model, prefix+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *copilot) generateAskRequest(histories []PromptMessage, opts AskOptions, stream bool, tools []map[string]any) interface{} {
+	isO1 := IsReasoningModel(opts.Model)
 	systemRole := defaultSystemRole
 	if isO1 {
 		systemRole = userRole
 	}
 	messages := make([]PromptMessage, 0)
-	if systemPrompt != "" {
+	if opts.SystemPrompt != "" {
 		messages = append(messages, PromptMessage{
-			Content: systemPrompt,
+			Content: opts.SystemPrompt,
 			Role:    systemRole,
 		})
 	}
@@ -211,55 +328,226 @@ func (c *copilot) generateAskRequest(histories []PromptMessage, prompt, systemPr
 		messages = append(messages, histories...)
 	}
 
-	if prompt != "" {
-		messages = append(messages, PromptMessage{
-			Content: prompt,
-			Role:    userRole,
-		})
-	}
-
 	body := map[string]any{
 		"messages": messages,
-		"model":    model,
+		"model":    opts.Model,
 		"stream":   stream,
-		"n":        1,
+		"n":        opts.N,
+	}
+	if stream {
+		// The usage of streamed responses only comes with a final chunk
+		// when asked for
+		body["stream_options"] = map[string]any{"include_usage": true}
 	}
 
-	if maxOutputToken > 0 {
-		body["max_tokens"] = maxOutputToken
+	if opts.MaxOutputTokens > 0 {
+		body["max_tokens"] = opts.MaxOutputTokens
 	}
 
 	if !isO1 {
-		body["temperature"] = temperature
-		body["top_p"] = 1
+		body["temperature"] = *opts.Temperature
+		body["top_p"] = *opts.TopP
+	}
+
+	if len(tools) > 0 {
+		body["tools"] = tools
 	}
 
 	return body
 }
 
+// resolveAskOptions fills the unset fields of opts with the defaults.
+func (c *copilot) resolveAskOptions(opts *AskOptions, model *Model) AskOptions {
+	resolved := AskOptions{}
+	if opts != nil {
+		resolved = *opts
+	}
+
+	if resolved.Model == "" {
+		resolved.Model = model.ID
+	}
+	if resolved.SystemPrompt == "" {
+		resolved.SystemPrompt = strings.TrimSpace(COPILOT_INSTRUCTIONS)
+	}
+	if resolved.Temperature == nil {
+		temperature := defaultTemperature
+		resolved.Temperature = &temperature
+	}
+	if resolved.TopP == nil {
+		topP := defaultTopP
+		resolved.TopP = &topP
+	}
+	if resolved.MaxOutputTokens <= 0 {
+		resolved.MaxOutputTokens = model.Capabilities.Limits.MaxOutputTokens
+	}
+	if resolved.N <= 0 {
+		resolved.N = defaultN
+	}
+
+	return resolved
+}
+
 // Ask implements Copilot.
-func (c *copilot) Ask(ctx context.Context, prompt string, opts any) (string, error) {
+func (c *copilot) Ask(ctx context.Context, prompt string, opts *AskOptions) (string, error) {
+	return c.conversation.Ask(ctx, prompt, opts)
+}
+
+// AskStream implements Copilot.
+func (c *copilot) AskStream(ctx context.Context, prompt string, opts *AskOptions, onChunk func(chunk string)) (string, error) {
+	return c.conversation.AskStream(ctx, prompt, opts, onChunk)
+}
+
+// AskCandidates implements Copilot.
+func (c *copilot) AskCandidates(ctx context.Context, prompt string, opts *AskOptions) ([]string, error) {
+	return c.conversation.AskCandidates(ctx, prompt, opts)
+}
+
+func (c *copilot) ask(ctx context.Context, conv *conversation, prompt string, opts *AskOptions, onChunk func(chunk string)) (string, error) {
 	prompt = strings.TrimSpace(prompt)
 
-	systemPrompt := strings.TrimSpace(COPILOT_INSTRUCTIONS)
-	temperature := defaultTemperature
+	model := ""
+	if opts != nil {
+		model = opts.Model
+	}
+	modelConfig, err := c.GetModel(ctx, model)
+	if err != nil {
+		return "", err
+	}
+
+	askOpts := c.resolveAskOptions(opts, modelConfig)
+	stream := onChunk != nil && modelConfig.Capabilities.Supports.Streaming
 
-	model := defaultModel
-	models, err := c.FetchModels(ctx)
+	path, isAgent, err := c.completionPath(ctx, askOpts.Agent)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch models: %w", err)
+		return "", err
+	}
+	if isAgent {
+		stream = true
 	}
 
-	modelConfig, ok := models[model]
-	if !ok {
-		return "", fmt.Errorf("model %s not found", model)
+	var tools []map[string]any
+	if !askOpts.DisableTools && !isAgent && modelConfig.Capabilities.Supports.ToolCalls {
+		tools = c.toolDefinitions()
 	}
 
-	capabilities := modelConfig.Capabilities
-	maxOutputToken := capabilities.Limits.MaxOutputTokens
-	stream := false
+	var histories []PromptMessage
+	if !askOpts.IgnoreHistory {
+		histories = conv.History()
+	}
+
+	// turn holds the messages of this exchange: the prompt, tool calls with
+	// their results and finally the answer
+	turn := []PromptMessage{{
+		Content: prompt,
+		Role:    userRole,
+	}}
+
+	var reply PromptMessage
+	// Responses cut off at the output limit are continued; the parts are
+	// merged into a single answer from continuedAt on
+	continued := ""
+	continuedAt := -1
+	truncated := false
+	for round, continuations := 0, 0; ; round++ {
+		messages := append(append([]PromptMessage(nil), histories...), turn...)
+		body := c.generateAskRequest(messages, askOpts, stream, tools)
+
+		result, err := c.complete(ctx, path, body, stream, onChunk)
+		if err != nil {
+			return "", err
+		}
+		c.recordUsage(askOpts.Model, result.Usage)
+
+		if err := result.filterError(); err != nil {
+			return "", err
+		}
+
+		reply = result.Message
+		if result.FinishReason == finishLength && len(reply.ToolCalls) == 0 {
+			if continuations < maxContinuations {
+				if continuedAt == -1 {
+					continuedAt = len(turn)
+				}
+				continued += reply.Content
+				turn = append(turn, reply, PromptMessage{
+					Content: continuePrompt,
+					Role:    userRole,
+				})
+				continuations++
+				continue
+			}
+			truncated = true
+		}
+
+		if continuedAt != -1 {
+			reply.Content = continued + reply.Content
+			turn = turn[:continuedAt]
+			continued, continuedAt = "", -1
+		}
+		turn = append(turn, reply)
+
+		if len(reply.ToolCalls) == 0 {
+			break
+		}
+		if round >= maxToolRounds {
+			return "", fmt.Errorf("model kept calling tools after %d rounds", maxToolRounds)
+		}
+		for _, call := range reply.ToolCalls {
+			turn = append(turn, c.callTool(ctx, call))
+		}
+	}
+
+	if !askOpts.NoAppendHistory {
+		conv.append(turn...)
+	}
+
+	if reply.Content == "" {
+		return "", fmt.Errorf("failed to get response")
+	}
+
+	if truncated {
+		return strings.TrimSpace(reply.Content), ErrTruncated
+	}
+
+	return strings.TrimSpace(reply.Content), nil
+}
+
+// completion is the outcome of a single chat completion request.
+type completion struct {
+	Message             PromptMessage
+	Usage               Usage
+	FinishReason        string
+	PromptFilterResults []PromptFilterResult
+	ContentFilter       ContentFilterResults
+	// Choices holds every choice of a response that isn't streamed
+	Choices []Choice
+}
+
+// filterError reports whether the content filter blocked the prompt or the
+// response.
+func (c completion) filterError() error {
+	for _, result := range c.PromptFilterResults {
+		if category, severity, ok := result.ContentFilterResults.Filtered(); ok {
+			return &ContentFilterError{Prompt: true, Category: category, Severity: severity}
+		}
+	}
+	if category, severity, ok := c.ContentFilter.Filtered(); ok {
+		return &ContentFilterError{Category: category, Severity: severity}
+	}
+	if c.FinishReason == finishContentFilter {
+		return &ContentFilterError{Category: "unknown"}
+	}
+	return nil
+}
+
+// complete sends a single chat completion request and returns the reply of
+// the assistant, passing streamed content to onChunk as it arrives.
+func (c *copilot) complete(ctx context.Context, path string, body any, stream bool, onChunk func(chunk string)) (completion, error) {
+	result := completion{}
+	reply := PromptMessage{Role: assistantRole}
+	var references []CopilotReference
+	var agentErr error
 
-	fullResponse := ""
 	parseLine := func(line string) {
 		if line == "" {
 			return
@@ -271,95 +559,228 @@ func (c *copilot) Ask(ctx context.Context, prompt string, opts any) (string, err
 			return
 		}
 
+		// Streams report the usage once, in a chunk of its own after the last
+		// choice
+		if res.Usage.TotalTokens > 0 {
+			result.Usage = res.Usage
+		}
+
+		result.PromptFilterResults = append(result.PromptFilterResults, res.PromptFilterResults...)
+		references = append(references, res.CopilotReferences...)
+		if len(res.CopilotErrors) > 0 && agentErr == nil {
+			agentErr = res.CopilotErrors[0]
+		}
+
 		if len(res.Choices) == 0 {
 			return
 		}
 
 		choice := res.Choices[0]
-		content := choice.Message.Content
-		if content != "" {
-			fullResponse += content
+		if choice.FinishReason != "" {
+			result.FinishReason = choice.FinishReason
+		}
+		if _, _, ok := choice.ContentFilterResults.Filtered(); ok {
+			result.ContentFilter = choice.ContentFilterResults
 		}
-	}
 
-	body := c.generateAskRequest(
-		c.histories,
-		prompt,
-		systemPrompt,
-		model,
-		temperature,
-		maxOutputToken,
-		stream,
-	)
+		message := choice.Message
+		if stream {
+			message = choice.Delta
+			reply.ToolCalls = mergeToolCallDeltas(reply.ToolCalls, message.ToolCalls)
+		} else {
+			reply.ToolCalls = message.ToolCalls
+			result.Choices = res.Choices
+		}
 
-	headers, err := c.generateHeaders(ctx)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate headers: %w", err)
+		content := message.Content
+		if content != "" {
+			reply.Content += content
+			if onChunk != nil {
+				onChunk(content)
+			}
+		}
 	}
 
-	res, err := utils.HttpRequest(ctx, utils.HttpOptions{
-		Method:  http.MethodPost,
-		Url:     apiURL + "/chat/completions",
-		Headers: headers,
-		Body:    body,
-	})
+	res, err := c.request(ctx, http.MethodPost, path, body)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return result, err
 	}
 
 	if res.StatusCode != 200 {
-		return "", fmt.Errorf("failed to fetch completion response (%d): %s", res.StatusCode, res.Status)
+		return result, responseError("failed to fetch completion response", res)
 	}
 
-	resBody, err := res.StringDecode()
-	if err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+	if stream {
+		err = readEventStream(res, parseLine)
+		if err != nil {
+			return result, fmt.Errorf("failed to read response stream: %w", err)
+		}
+	} else {
+		resBody, err := res.StringDecode()
+		if err != nil {
+			return result, fmt.Errorf("failed to decode response: %w", err)
+		}
+		parseLine(resBody)
 	}
 
-	if !stream {
-		parseLine(resBody)
+	if agentErr != nil {
+		return result, agentErr
 	}
 
-	c.histories = append(c.histories, PromptMessage{
-		Content: prompt,
-		Role:    userRole,
-	})
+	if refs := formatReferences(references); refs != "" {
+		reply.Content += refs
+		if onChunk != nil {
+			onChunk(refs)
+		}
+	}
+
+	// The index only matters while merging streamed deltas
+	for i := range reply.ToolCalls {
+		reply.ToolCalls[i].Index = 0
+	}
 
-	c.histories = append(c.histories, PromptMessage{
-		Content: fullResponse,
-		Role:    assistantRole,
+	result.Message = reply
+	return result, nil
+}
+
+// recordUsage adds the usage of a request to the local ledger. The ledger is
+// informational, so failing to write it doesn't fail the request. Responses
+// that didn't report their usage are left out.
+func (c *copilot) recordUsage(model string, u Usage) {
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 && u.TotalTokens == 0 {
+		return
+	}
+	_ = usage.Append(usage.Record{
+		Model:            model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		CachedTokens:     u.PromptTokensDetails.CachedTokens,
+		ReasoningTokens:  u.CompletionTokensDetails.ReasoningTokens,
+		TotalTokens:      u.TotalTokens,
 	})
+}
 
-	if fullResponse == "" {
-		return "", fmt.Errorf("failed to get response")
+// readEventStream reads a server-sent events response and passes the payload
+// of every event to fn until the `[DONE]` marker or end of body. The `data:`
+// lines of an event are joined with newlines, other fields are ignored.
+func readEventStream(res *utils.HttpResponse, fn func(data string)) error {
+	defer res.Body.Close()
+
+	lines := make([]string, 0, 1)
+	// dispatch passes the event read so far to fn and reports whether the
+	// stream is done
+	dispatch := func() bool {
+		if len(lines) == 0 {
+			return false
+		}
+		data := strings.Join(lines, "\n")
+		lines = lines[:0]
+		if strings.TrimSpace(data) == "[DONE]" {
+			return true
+		}
+		fn(data)
+		return false
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			if dispatch() {
+				return nil
+			}
+			continue
+		}
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			lines = append(lines, strings.TrimPrefix(data, " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
 	}
+	// The last event may miss its blank line
+	dispatch()
+	return nil
+}
+
+// History implements Copilot.
+func (c *copilot) History() []PromptMessage {
+	return c.conversation.History()
+}
 
-	return strings.TrimSpace(fullResponse), nil
+// SetHistory implements Copilot.
+func (c *copilot) SetHistory(messages []PromptMessage) {
+	c.conversation.SetHistory(messages)
+}
+
+// GetModel implements Copilot.
+func (c *copilot) GetModel(ctx context.Context, id string) (*Model, error) {
+	if id == "" {
+		id = c.defaultModel
+	}
+
+	models, err := c.FetchModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch models: %w", err)
+	}
+
+	if id == "" {
+		// Servers hosting a single model don't need a default
+		if len(models) != 1 {
+			return nil, fmt.Errorf("no default model set for the %s provider", c.provider.Name())
+		}
+		for _, model := range models {
+			return model, nil
+		}
+	}
+
+	model, ok := models[id]
+	if !ok {
+		// The model may have been released after the cache was fetched
+		models, err = c.RefreshModels(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh models: %w", err)
+		}
+		model, ok = models[id]
+	}
+	if !ok {
+		return nil, fmt.Errorf("model %s not found", id)
+	}
+
+	return model, nil
 }
 
 // FetchAgents implements Copilot.
 func (c *copilot) FetchAgents(ctx context.Context) (map[string]*Agent, error) {
-	if len(c.agents) > 0 {
-		return c.agents, nil
+	c.mu.RLock()
+	agents := c.agents
+	c.mu.RUnlock()
+	if agents.isFresh() {
+		return agents.Data, nil
 	}
+	return c.RefreshAgents(ctx)
+}
 
-	headers, err := c.generateHeaders(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate headers: %w", err)
+// RefreshAgents implements Copilot.
+func (c *copilot) RefreshAgents(ctx context.Context) (map[string]*Agent, error) {
+	return c.agentsFlight.do(ctx, c.refreshAgents)
+}
+
+func (c *copilot) refreshAgents(ctx context.Context) (map[string]*Agent, error) {
+	if !c.provider.SupportsAgents() {
+		return nil, fmt.Errorf("agents are not available with the %s provider", c.provider.Name())
 	}
 
-	res, err := utils.HttpRequest(ctx, utils.HttpOptions{
-		Method:  http.MethodGet,
-		Url:     apiURL + "/agents",
-		Headers: headers,
-	})
+	res, err := c.request(ctx, http.MethodGet, "/agents", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 
 	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to fetch agents (%d): %s", res.StatusCode, res.Status)
+		return nil, responseError("failed to fetch agents", res)
 	}
+	endpoint := res.Request.URL.String()
 
 	var restResponse struct {
 		Agents []*Agent `json:"agents"`
@@ -370,49 +791,58 @@ func (c *copilot) FetchAgents(ctx context.Context) (map[string]*Agent, error) {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	agents := newCache[Agent]()
+	agents.FetchedAt = time.Now()
+	agents.Endpoint = endpoint
 	for _, a := range restResponse.Agents {
-		c.agents[a.Slug] = a
+		agents.Data[a.Slug] = a
 	}
 
-	c.agents["copilot"] = &Agent{
+	agents.Data[DefaultAgent] = &Agent{
 		Name:        "copilot",
 		Slug:        "copilot",
 		Default:     true,
 		Description: "Default noop agent",
 	}
+	c.mu.Lock()
+	c.agents = agents
+	c.mu.Unlock()
 
-	err = utils.SaveFile(utils.GetConfigPath()+"/lazycopilot/agents.json", c.agents)
+	err = agents.save(c.cachePath("agents"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to save agents to file: %w", err)
 	}
 
-	return c.agents, nil
+	return agents.Data, nil
 }
 
 // FetchModels implements Copilot.
 func (c *copilot) FetchModels(ctx context.Context) (map[string]*Model, error) {
-	if len(c.models) > 0 {
-		return c.models, nil
+	c.mu.RLock()
+	models := c.models
+	c.mu.RUnlock()
+	if models.isFresh() {
+		return models.Data, nil
 	}
+	return c.RefreshModels(ctx)
+}
 
-	headers, err := c.generateHeaders(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate headers: %w", err)
-	}
+// RefreshModels implements Copilot.
+func (c *copilot) RefreshModels(ctx context.Context) (map[string]*Model, error) {
+	return c.modelsFlight.do(ctx, c.refreshModels)
+}
 
-	res, err := utils.HttpRequest(ctx, utils.HttpOptions{
-		Method:  http.MethodGet,
-		Url:     apiURL + "/models",
-		Headers: headers,
-	})
+func (c *copilot) refreshModels(ctx context.Context) (map[string]*Model, error) {
+	res, err := c.request(ctx, http.MethodGet, "/models", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to fetch models (%d): %s", res.StatusCode, res.Status)
+		return nil, responseError("failed to fetch models", res)
 	}
+	endpoint := res.Request.URL.String()
 
 	var results struct {
 		Data []*Model `json:"data"`
@@ -422,109 +852,49 @@ func (c *copilot) FetchModels(ctx context.Context) (map[string]*Model, error) {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	for _, model := range results.Data {
-		if model.ModelPickerEnabled {
-			c.models[model.ID] = model
-		}
+	models := newCache[Model]()
+	models.FetchedAt = time.Now()
+	models.Endpoint = endpoint
+	for _, model := range c.provider.ChatModels(results.Data) {
+		models.Data[model.ID] = model
 	}
+	c.mu.Lock()
+	c.models = models
+	c.mu.Unlock()
 
-	err = utils.SaveFile(utils.GetConfigPath()+"/lazycopilot/models.json", c.models)
+	err = models.save(c.cachePath("models"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to save models to file: %w", err)
 	}
 
-	return c.models, nil
+	return models.Data, nil
 }
 
-func (c *copilot) authenticate(ctx context.Context) error {
-	if c.githubToken == nil || *c.githubToken == "" {
-		return fmt.Errorf("GitHub token not set. Please authenticate using 'lazycopilot auth login' command")
-	}
-
-	if c.token == nil || (c.token.ExpiresAt != 0 && c.token.ExpiresAt <= int(time.Now().Unix())) {
-		sessionId := uuid.New().String() + "-" + fmt.Sprint(time.Now().UnixMicro())
-		res, err := utils.HttpRequest(ctx, utils.HttpOptions{
-			Method: http.MethodGet,
-			Url:    "https://api.github.com/copilot_internal/v2/token",
-			Headers: &utils.Headers{
-				authorizationHeader: "Bearer " + *c.githubToken,
-				acceptHeader:        applicationJSON,
-			},
-		})
-		if err != nil {
-			return fmt.Errorf("failed to request token: %w", err)
-		}
-		if res.StatusCode != 200 {
-			if res.StatusCode == 403 {
-				return fmt.Errorf("failed to authenticate: invalid token. Please check if your GitHub account has Copilot active at https://github.com/settings/copilot")
-			}
-			return fmt.Errorf("failed to authenticate (%d): %s", res.StatusCode, res.Status)
-		}
-		var token GithubToken
-		err = res.JsonDecode(&token)
+// request sends an authenticated request to the API of the provider. When
+// the credentials are rejected they are renewed and the request is sent once
+// more.
+func (c *copilot) request(ctx context.Context, method, path string, body any) (*utils.HttpResponse, error) {
+	for attempt := 0; ; attempt++ {
+		auth, err := c.provider.Authorize(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to decode token response: %w", err)
+			return nil, fmt.Errorf("failed to authorize request: %w", err)
 		}
-		c.sessionId = sessionId
-		c.token = &token
 
-		err = utils.SaveFile(utils.GetConfigPath()+"/lazycopilot/token.json", c.token)
+		res, err := utils.HttpRequest(ctx, utils.HttpOptions{
+			Method:  method,
+			Url:     auth.BaseURL + path,
+			Headers: &auth.Headers,
+			Body:    body,
+		})
 		if err != nil {
-			return fmt.Errorf("failed to save token to file: %w", err)
+			return nil, fmt.Errorf("failed to send request: %w", err)
 		}
-	}
-
-	if c.sessionId == "" {
-		c.sessionId = uuid.New().String() + "-" + fmt.Sprint(time.Now().UnixMicro())
-	}
-
-	return nil
-}
-
-func (c *copilot) generateHeaders(ctx context.Context) (*utils.Headers, error) {
-	err := c.authenticate(ctx)
-	if err != nil {
-		return nil, err
-	}
-	headers := &utils.Headers{
-		authorizationHeader:      "Bearer " + c.token.Token,
-		"x-request-id":           uuid.New().String(),
-		"vscode-sessionid":       c.sessionId,
-		"vscode-machineid":       c.machineId,
-		"copilot-integration-id": copilotIntegrationID,
-		"openai-organization":    openaiOrganization,
-		"openai-intent":          openaiIntent,
-		contentTypeHeader:        applicationJSON,
-	}
-	return headers, nil
-}
-
-func (c *copilot) getCachedToken() *string {
-	configPath := utils.GetConfigPath()
 
-	// token can be stored in apps.json or hosts.json
-	filePaths := []string{
-		configPath + "/github-copilot/hosts.json",
-		configPath + "/github-copilot/apps.json",
-	}
-
-	for _, path := range filePaths {
-		if utils.IsFileExists(path) {
-			f, err := os.Open(path)
-			if err != nil {
-				return nil
-			}
-			defer f.Close()
-			var token map[string]utils.CachedToken
-			if err := json.NewDecoder(f).Decode(&token); err != nil {
-				return nil
-			}
-			for key, value := range token {
-				if strings.HasPrefix(key, "github.com") {
-					return &value.OauthToken
-				}
-			}
+		if res.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			res.Body.Close()
+			c.provider.Reject(auth)
+			continue
 		}
+		return res, nil
 	}
-	return nil
 }
@@ -1,5 +1,83 @@
 package copilot
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mr687/lazycopilot/pkg/utils"
+)
 
 var ErrGithubTokenNotSet = errors.New("no GitHub token found, please use `:Copilot auth` to set it up from copilot.lua or `:Copilot setup` for copilot.vim")
+
+var (
+	ErrRateLimited        = errors.New("rate limit exceeded")
+	ErrUnauthorized       = errors.New("not authorized")
+	ErrServiceUnavailable = errors.New("service unavailable")
+)
+
+// responseError describes an unsuccessful response and closes its body. Rate
+// limits, rejected credentials and outages wrap ErrRateLimited,
+// ErrUnauthorized and ErrServiceUnavailable respectively.
+func responseError(action string, res *utils.HttpResponse) error {
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		hint := "Please wait a moment and try again"
+		if retryAfter, ok := utils.RetryAfter(res.Response); ok {
+			hint = fmt.Sprintf("Please try again in %s", retryAfter.Round(time.Second))
+		}
+		return fmt.Errorf("%s: %w. %s", action, ErrRateLimited, hint)
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%s: %w (%d). Please authenticate again using 'lazycopilot auth login'", action, ErrUnauthorized, res.StatusCode)
+	case res.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%s: %w (%d). GitHub Copilot may be experiencing an outage, see https://www.githubstatus.com", action, ErrServiceUnavailable, res.StatusCode)
+	default:
+		return fmt.Errorf("%s (%d): %s", action, res.StatusCode, res.Status)
+	}
+}
+
+// ErrTruncated is returned along with the partial content when the response
+// still hit the output limit after continuing it.
+var ErrTruncated = errors.New("response truncated at the output token limit")
+
+// ContentFilterError is returned when the content filter blocked the prompt
+// or the response.
+type ContentFilterError struct {
+	// Prompt is set when the prompt was filtered rather than the response.
+	Prompt   bool
+	Category string
+	Severity string
+}
+
+func (e *ContentFilterError) Error() string {
+	subject := "response"
+	if e.Prompt {
+		subject = "prompt"
+	}
+	if e.Severity == "" {
+		return fmt.Sprintf("%s blocked by the content filter (category: %s)", subject, e.Category)
+	}
+	return fmt.Sprintf("%s blocked by the content filter (category: %s, severity: %s)", subject, e.Category, e.Severity)
+}
+
+// Filtered returns the first category the content filter blocked.
+func (r ContentFilterResults) Filtered() (category string, severity string, ok bool) {
+	categories := []struct {
+		name   string
+		result Hate
+	}{
+		{"hate", r.Hate},
+		{"self_harm", r.SelfHarm},
+		{"sexual", r.Sexual},
+		{"violence", r.Violence},
+	}
+	for _, c := range categories {
+		if c.result.Filtered {
+			return c.name, c.result.Severity, true
+		}
+	}
+	return "", "", false
+}
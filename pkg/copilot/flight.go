@@ -0,0 +1,72 @@
+package copilot
+
+import (
+	"context"
+	"sync"
+)
+
+// flight deduplicates concurrent calls: callers arriving while a call is in
+// progress wait for it and share its result instead of starting another.
+type flight[T any] struct {
+	mu   sync.Mutex
+	call *flightCall[T]
+}
+
+type flightCall[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+
+	// waiters counts the callers still waiting, the call is cancelled once
+	// all of them gave up
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// do runs fn, or joins the call in progress, and waits for the result until
+// ctx is done. The call doesn't stop when the caller that started it gives
+// up, so that the others still get its result.
+func (f *flight[T]) do(ctx context.Context, fn func(ctx context.Context) (T, error)) (T, error) {
+	f.mu.Lock()
+	call := f.call
+	if call == nil {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &flightCall[T]{done: make(chan struct{}), cancel: cancel}
+		f.call = call
+		go f.run(callCtx, call, fn)
+	}
+	call.waiters++
+	f.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+		f.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			// Later callers start over instead of joining a cancelled call
+			if f.call == call {
+				f.call = nil
+			}
+		}
+		f.mu.Unlock()
+
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func (f *flight[T]) run(ctx context.Context, call *flightCall[T], fn func(ctx context.Context) (T, error)) {
+	defer call.cancel()
+	val, err := fn(ctx)
+
+	f.mu.Lock()
+	call.val, call.err = val, err
+	if f.call == call {
+		f.call = nil
+	}
+	f.mu.Unlock()
+	close(call.done)
+}
@@ -0,0 +1,117 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightSharesOneCall(t *testing.T) {
+	var f flight[int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	results := make(chan int, 3)
+	for range 3 {
+		go func() {
+			val, _ := f.do(context.Background(), fn)
+			results <- val
+		}()
+	}
+	waitForWaiters(t, &f, 3)
+	close(release)
+
+	for range 3 {
+		if val := <-results; val != 42 {
+			t.Errorf("got %d, want 42", val)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn ran %d times, want 1", n)
+	}
+}
+
+func TestFlightCancelledCallerDoesNotFailOthers(t *testing.T) {
+	var f flight[int]
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		select {
+		case <-release:
+			return 42, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	// The first caller starts the call and gives up
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := f.do(first, fn)
+		firstErr <- err
+	}()
+	waitForWaiters(t, &f, 1)
+
+	other := make(chan int, 1)
+	go func() {
+		val, _ := f.do(context.Background(), fn)
+		other <- val
+	}()
+	waitForWaiters(t, &f, 2)
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller got %v, want context.Canceled", err)
+	}
+	close(release)
+	if val := <-other; val != 42 {
+		t.Errorf("other caller got %d, want 42", val)
+	}
+}
+
+func TestFlightCancelsCallWithoutWaiters(t *testing.T) {
+	var f flight[int]
+	stopped := make(chan error, 1)
+	fn := func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return 0, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { _, _ = f.do(ctx, fn) }()
+	waitForWaiters(t, &f, 1)
+	cancel()
+
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("call stopped with %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("call kept running after every caller gave up")
+	}
+}
+
+// waitForWaiters waits until n callers wait for the call in flight.
+func waitForWaiters[T any](t *testing.T, f *flight[T], n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		waiting := f.call != nil && f.call.waiters == n
+		f.mu.Unlock()
+		if waiting {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d callers", n)
+}
@@ -0,0 +1,79 @@
+package copilot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/utils"
+)
+
+// Provider connects the client to a chat completions API. The client builds
+// the requests, the provider supplies the endpoint and the credentials.
+type Provider interface {
+	// Name identifies the provider in the settings, e.g. "copilot".
+	Name() string
+	// CacheKey tells apart the caches of the APIs a provider can talk to,
+	// the default API having none.
+	CacheKey() string
+	// Authorize returns the base URL and the headers authenticating a
+	// request, renewing expired credentials first.
+	Authorize(ctx context.Context) (*Authorization, error)
+	// Reject is called when the API refused auth, so that the next call to
+	// Authorize renews the credentials.
+	Reject(auth *Authorization)
+	// ChatModels picks the models listed by the models endpoint that can be
+	// used for chat, filling in what the endpoint doesn't report.
+	ChatModels(models []*Model) []*Model
+	// SupportsAgents reports whether the API serves Copilot agents.
+	SupportsAgents() bool
+}
+
+// BackgroundRefresher is implemented by providers whose credentials expire,
+// to renew them ahead of time in long-lived sessions.
+type BackgroundRefresher interface {
+	// RefreshInBackground starts renewing the credentials until ctx is done
+	// and returns immediately.
+	RefreshInBackground(ctx context.Context)
+}
+
+// Authorization is what a request needs to reach the API of a provider.
+type Authorization struct {
+	BaseURL string
+	Headers utils.Headers
+	// Credential identifies the credentials in use, e.g. for Reject.
+	Credential string
+}
+
+// NewProvider returns the provider selected in the settings.
+func NewProvider(settings *config.Settings) (Provider, error) {
+	switch name := settings.GetProvider(); name {
+	case config.PROVIDER_COPILOT:
+		return newCopilotProvider(settings), nil
+	case config.PROVIDER_OPENAI:
+		return newOpenAIProvider(settings.OpenAI)
+	default:
+		return nil, fmt.Errorf("unknown provider '%s', available providers: %s, %s", name, config.PROVIDER_COPILOT, config.PROVIDER_OPENAI)
+	}
+}
+
+// unavailableProvider stands in for a provider that couldn't be set up and
+// reports why on every request.
+type unavailableProvider struct {
+	name string
+	err  error
+}
+
+func (p *unavailableProvider) Name() string { return p.name }
+
+func (p *unavailableProvider) CacheKey() string { return "unavailable" }
+
+func (p *unavailableProvider) Authorize(ctx context.Context) (*Authorization, error) {
+	return nil, p.err
+}
+
+func (p *unavailableProvider) Reject(auth *Authorization) {}
+
+func (p *unavailableProvider) ChatModels(models []*Model) []*Model { return nil }
+
+func (p *unavailableProvider) SupportsAgents() bool { return false }
@@ -0,0 +1,345 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/utils"
+)
+
+const (
+	// tokenExpiryMargin is how long before its expiry a token is replaced
+	// when the API didn't say when to refresh it.
+	tokenExpiryMargin = time.Minute
+	// tokenRetryDelay is how long a failed background refresh waits before
+	// trying again.
+	tokenRetryDelay = 30 * time.Second
+)
+
+// errNotLoggedIn is returned while there is no GitHub token to exchange.
+var errNotLoggedIn = errors.New("GitHub token not set. Please authenticate using 'lazycopilot auth login' command")
+
+// copilotProvider exchanges the GitHub token of copilot.lua or copilot.vim
+// for a short-lived Copilot session token.
+type copilotProvider struct {
+	machineId string
+	// apiURLOverride takes precedence over the endpoint of the token
+	apiURLOverride string
+	tokenURL       string
+	githubToken    *string
+
+	// mu guards the fields below
+	mu        sync.RWMutex
+	sessionId string
+	token     *GithubToken
+
+	tokenFlight flight[*GithubToken]
+}
+
+func newCopilotProvider(settings *config.Settings) *copilotProvider {
+	p := &copilotProvider{
+		machineId:      utils.GenerateMachineId(),
+		apiURLOverride: settings.GetAPIURL(),
+		tokenURL:       tokenURL,
+	}
+	if url := settings.GetTokenURL(); url != "" {
+		p.tokenURL = url
+	}
+
+	p.githubToken = p.getCachedToken()
+	_ = utils.LoadFileJson(utils.GetConfigPath()+"/lazycopilot/token.json", &p.token)
+	return p
+}
+
+// Name implements Provider.
+func (p *copilotProvider) Name() string {
+	return config.PROVIDER_COPILOT
+}
+
+// CacheKey implements Provider.
+func (p *copilotProvider) CacheKey() string {
+	return ""
+}
+
+// Authorize implements Provider.
+func (p *copilotProvider) Authorize(ctx context.Context) (*Authorization, error) {
+	token, sessionId, err := p.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Authorization{
+		BaseURL:    p.apiURL(token),
+		Headers:    p.generateHeaders(token, sessionId),
+		Credential: token.Token,
+	}, nil
+}
+
+// Reject implements Provider. A token that was already replaced by another
+// request is left alone.
+func (p *copilotProvider) Reject(auth *Authorization) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != nil && p.token.Token == auth.Credential {
+		p.token = nil
+	}
+}
+
+// ChatModels implements Provider.
+func (p *copilotProvider) ChatModels(models []*Model) []*Model {
+	chat := make([]*Model, 0, len(models))
+	for _, model := range models {
+		if model.ModelPickerEnabled {
+			chat = append(chat, model)
+		}
+	}
+	return chat
+}
+
+// SupportsAgents implements Provider.
+func (p *copilotProvider) SupportsAgents() bool {
+	return true
+}
+
+// authenticate returns a valid session token along with the session id,
+// exchanging the GitHub token for a new one when it is missing or expired.
+func (p *copilotProvider) authenticate(ctx context.Context) (*GithubToken, string, error) {
+	if !p.hasGithubToken() {
+		return nil, "", errNotLoggedIn
+	}
+
+	p.mu.Lock()
+	token := p.token
+	if p.sessionId == "" {
+		p.sessionId = newSessionId()
+	}
+	sessionId := p.sessionId
+	p.mu.Unlock()
+	if token != nil && !token.refreshDue() {
+		return token, sessionId, nil
+	}
+
+	fresh, err := p.tokenFlight.do(ctx, p.refreshToken)
+	if err != nil {
+		// A token due for refresh keeps working until it expires
+		if token != nil && !token.expired() {
+			return token, sessionId, nil
+		}
+		return nil, "", err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return fresh, p.sessionId, nil
+}
+
+func (p *copilotProvider) hasGithubToken() bool {
+	return p.githubToken != nil && *p.githubToken != ""
+}
+
+func (p *copilotProvider) refreshToken(ctx context.Context) (*GithubToken, error) {
+	if !p.hasGithubToken() {
+		return nil, errNotLoggedIn
+	}
+
+	// Another caller may have refreshed the token in the meantime
+	p.mu.RLock()
+	current := p.token
+	p.mu.RUnlock()
+	if current != nil && !current.refreshDue() {
+		return current, nil
+	}
+
+	headers := versionHeaders(utils.Headers{
+		authorizationHeader: "Bearer " + *p.githubToken,
+		acceptHeader:        applicationJSON,
+	})
+	res, err := utils.HttpRequest(ctx, utils.HttpOptions{
+		Method:  http.MethodGet,
+		Url:     p.tokenURL,
+		Headers: &headers,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request token: %w", err)
+	}
+	if res.StatusCode != 200 {
+		if res.StatusCode == 403 {
+			res.Body.Close()
+			return nil, fmt.Errorf("failed to authenticate: invalid token. Please check if your GitHub account has Copilot active at https://github.com/settings/copilot")
+		}
+		return nil, responseError("failed to authenticate", res)
+	}
+	var token GithubToken
+	err = res.JsonDecode(&token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if token.RefreshIn > 0 {
+		token.RefreshAt = time.Now().Add(time.Duration(token.RefreshIn) * time.Second).Unix()
+	}
+
+	p.mu.Lock()
+	p.sessionId = newSessionId()
+	p.token = &token
+	p.mu.Unlock()
+
+	// Other processes may read the file at any time
+	err = utils.SaveFileAtomic(utils.GetConfigPath()+"/lazycopilot/token.json", &token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save token to file: %w", err)
+	}
+
+	return &token, nil
+}
+
+// RefreshInBackground implements BackgroundRefresher. Without a GitHub token
+// there is nothing to refresh and requests report the missing login.
+func (p *copilotProvider) RefreshInBackground(ctx context.Context) {
+	if !p.hasGithubToken() {
+		return
+	}
+
+	go func() {
+		for first := true; ; first = false {
+			wait := p.untilRefresh()
+			// A token that is still due after a refresh, because the refresh
+			// failed or the API asked for it, is retried later instead of
+			// right away
+			if wait == 0 && !first {
+				wait = tokenRetryDelay
+			}
+			if !sleep(ctx, wait) {
+				return
+			}
+
+			// The next request reports the error if it persists
+			_, _ = p.tokenFlight.do(ctx, p.refreshToken)
+		}
+	}()
+}
+
+// untilRefresh returns how long the current token may still be used.
+func (p *copilotProvider) untilRefresh() time.Duration {
+	p.mu.RLock()
+	token := p.token
+	p.mu.RUnlock()
+
+	if token == nil {
+		return 0
+	}
+	at, ok := token.refreshTime()
+	if !ok {
+		return time.Hour
+	}
+	return max(time.Until(at), 0)
+}
+
+// sleep waits for d and reports whether ctx is still alive.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (t *GithubToken) expired() bool {
+	return t.ExpiresAt != 0 && int64(t.ExpiresAt) <= time.Now().Unix()
+}
+
+// refreshTime returns when the token should be replaced, which is unknown
+// for tokens that don't expire.
+func (t *GithubToken) refreshTime() (time.Time, bool) {
+	if t.RefreshAt != 0 {
+		return time.Unix(t.RefreshAt, 0), true
+	}
+	if t.ExpiresAt != 0 {
+		return time.Unix(int64(t.ExpiresAt), 0).Add(-tokenExpiryMargin), true
+	}
+	return time.Time{}, false
+}
+
+func (t *GithubToken) refreshDue() bool {
+	at, ok := t.refreshTime()
+	return ok && !time.Now().Before(at)
+}
+
+func newSessionId() string {
+	return uuid.New().String() + "-" + fmt.Sprint(time.Now().UnixMicro())
+}
+
+// apiURL returns the base URL for API requests. It honors the configured
+// override first, then the endpoint advertised by the token, which differs
+// for business and enterprise plans.
+func (p *copilotProvider) apiURL(token *GithubToken) string {
+	if p.apiURLOverride != "" {
+		return p.apiURLOverride
+	}
+	if token != nil && token.Endpoints.API != "" {
+		return strings.TrimSuffix(token.Endpoints.API, "/")
+	}
+	return apiURL
+}
+
+func (p *copilotProvider) generateHeaders(token *GithubToken, sessionId string) utils.Headers {
+	return versionHeaders(utils.Headers{
+		authorizationHeader:      "Bearer " + token.Token,
+		"x-request-id":           uuid.New().String(),
+		"vscode-sessionid":       sessionId,
+		"vscode-machineid":       p.machineId,
+		"copilot-integration-id": copilotIntegrationID,
+		"openai-organization":    openaiOrganization,
+		"openai-intent":          openaiIntent,
+		contentTypeHeader:        applicationJSON,
+	})
+}
+
+// versionHeaders adds the editor and plugin versions Copilot expects to
+// headers.
+func versionHeaders(headers utils.Headers) utils.Headers {
+	for key, value := range utils.VERSION_HEADERS {
+		headers[key] = value
+	}
+	return headers
+}
+
+func (p *copilotProvider) getCachedToken() *string {
+	configPath := utils.GetConfigPath()
+
+	// token can be stored in apps.json or hosts.json
+	filePaths := []string{
+		configPath + "/github-copilot/hosts.json",
+		configPath + "/github-copilot/apps.json",
+	}
+
+	for _, path := range filePaths {
+		if utils.IsFileExists(path) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil
+			}
+			defer f.Close()
+			var token map[string]utils.CachedToken
+			if err := json.NewDecoder(f).Decode(&token); err != nil {
+				return nil
+			}
+			for key, value := range token {
+				if strings.HasPrefix(key, "github.com") {
+					return &value.OauthToken
+				}
+			}
+		}
+	}
+	return nil
+}
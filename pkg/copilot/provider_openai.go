@@ -0,0 +1,93 @@
+package copilot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/mr687/lazycopilot/pkg/config"
+	"github.com/mr687/lazycopilot/pkg/utils"
+)
+
+// openAIProvider talks to any OpenAI compatible chat completions API, such
+// as llama.cpp, vLLM or Ollama.
+type openAIProvider struct {
+	baseURL         string
+	apiKey          string
+	maxPromptTokens int
+	maxOutputTokens int
+	toolCalls       bool
+}
+
+func newOpenAIProvider(settings *config.OpenAISettings) (*openAIProvider, error) {
+	if settings == nil || settings.BaseURL == "" {
+		return nil, errors.New("the openai provider needs a base URL, set openai.base_url in the settings")
+	}
+	return &openAIProvider{
+		baseURL:         strings.TrimSuffix(settings.BaseURL, "/"),
+		apiKey:          settings.GetAPIKey(),
+		maxPromptTokens: settings.MaxPromptTokens,
+		maxOutputTokens: settings.MaxOutputTokens,
+		toolCalls:       settings.ToolCalls,
+	}, nil
+}
+
+// Name implements Provider.
+func (p *openAIProvider) Name() string {
+	return config.PROVIDER_OPENAI
+}
+
+// CacheKey implements Provider. Every server lists models of its own.
+func (p *openAIProvider) CacheKey() string {
+	sum := sha256.Sum256([]byte(p.baseURL))
+	return p.Name() + "-" + hex.EncodeToString(sum[:4])
+}
+
+// Authorize implements Provider. Nothing but the key is sent along, the
+// headers Copilot expects mean nothing to other servers.
+func (p *openAIProvider) Authorize(ctx context.Context) (*Authorization, error) {
+	headers := utils.Headers{
+		contentTypeHeader: applicationJSON,
+	}
+	// Local servers usually don't check the key
+	if p.apiKey != "" {
+		headers[authorizationHeader] = "Bearer " + p.apiKey
+	}
+	return &Authorization{
+		BaseURL:    p.baseURL,
+		Headers:    headers,
+		Credential: p.apiKey,
+	}, nil
+}
+
+// Reject implements Provider. A static API key can't be renewed.
+func (p *openAIProvider) Reject(auth *Authorization) {}
+
+// ChatModels implements Provider. The models endpoint of OpenAI compatible
+// servers only lists ids, so every model is assumed to stream within the
+// configured limits, and to call tools when the settings say so.
+func (p *openAIProvider) ChatModels(models []*Model) []*Model {
+	for _, model := range models {
+		if model.Name == "" {
+			model.Name = model.ID
+		}
+		model.ModelPickerEnabled = true
+		model.Capabilities.Type = "chat"
+		model.Capabilities.Supports.Streaming = true
+		model.Capabilities.Supports.ToolCalls = p.toolCalls
+		if p.maxPromptTokens > 0 {
+			model.Capabilities.Limits.MaxPromptTokens = p.maxPromptTokens
+		}
+		if p.maxOutputTokens > 0 {
+			model.Capabilities.Limits.MaxOutputTokens = p.maxOutputTokens
+		}
+	}
+	return models
+}
+
+// SupportsAgents implements Provider.
+func (p *openAIProvider) SupportsAgents() bool {
+	return false
+}
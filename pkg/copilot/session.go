@@ -0,0 +1,136 @@
+package copilot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mr687/lazycopilot/pkg/utils"
+)
+
+var sessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Session is a named conversation persisted between invocations.
+type Session struct {
+	Name      string          `json:"name"`
+	Model     string          `json:"model,omitempty"`
+	Messages  []PromptMessage `json:"messages"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+func GetSessionsPath() string {
+	return utils.GetConfigPath() + "/lazycopilot/sessions"
+}
+
+func sessionPath(name string) (string, error) {
+	if !sessionNamePattern.MatchString(name) || strings.Trim(name, ".") == "" {
+		return "", fmt.Errorf("invalid session name '%s': use letters, digits, '.', '_' and '-' only", name)
+	}
+	return filepath.Join(GetSessionsPath(), name+".json"), nil
+}
+
+// NewSession returns an empty session that is not saved yet.
+func NewSession(name string) *Session {
+	now := time.Now()
+	return &Session{
+		Name:      name,
+		Messages:  make([]PromptMessage, 0),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func LoadSession(name string) (*Session, error) {
+	path, err := sessionPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := utils.LoadFileJson(path, &session); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("session '%s' not found", name)
+		}
+		return nil, fmt.Errorf("failed to load session '%s': %w", name, err)
+	}
+	return &session, nil
+}
+
+// LoadOrNewSession loads the session or starts a new one when it doesn't
+// exist yet.
+func LoadOrNewSession(name string) (*Session, error) {
+	path, err := sessionPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if !utils.IsFileExists(path) {
+		return NewSession(name), nil
+	}
+	return LoadSession(name)
+}
+
+// SessionExists reports whether a session of that name was saved.
+func SessionExists(name string) (bool, error) {
+	path, err := sessionPath(name)
+	if err != nil {
+		return false, err
+	}
+	return utils.IsFileExists(path), nil
+}
+
+func SaveSession(session *Session) error {
+	path, err := sessionPath(session.Name)
+	if err != nil {
+		return err
+	}
+
+	session.UpdatedAt = time.Now()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = session.UpdatedAt
+	}
+	return utils.SaveFile(path, session)
+}
+
+func DeleteSession(name string) error {
+	path, err := sessionPath(name)
+	if err != nil {
+		return err
+	}
+	if !utils.IsFileExists(path) {
+		return fmt.Errorf("session '%s' not found", name)
+	}
+	return os.Remove(path)
+}
+
+// ListSessions returns all saved sessions, most recently updated first.
+func ListSessions() ([]*Session, error) {
+	entries, err := os.ReadDir(GetSessionsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Session{}, nil
+		}
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		session, err := LoadSession(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
@@ -0,0 +1,74 @@
+package copilot
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mr687/lazycopilot/pkg/utils"
+)
+
+func TestReadEventStream(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "events",
+			body: "data: {\"a\":1}\n\ndata: {\"a\":2}\n\n",
+			want: []string{`{"a":1}`, `{"a":2}`},
+		},
+		{
+			name: "stops at done",
+			body: "data: one\n\ndata: [DONE]\n\ndata: after\n\n",
+			want: []string{"one"},
+		},
+		{
+			name: "multi-line data",
+			body: "data: {\"a\":\ndata: 1}\n\ndata: two\n\n",
+			want: []string{"{\"a\":\n1}", "two"},
+		},
+		{
+			name: "other fields and comments",
+			body: ": keep-alive\nevent: message\nid: 1\ndata: one\nretry: 100\n\n",
+			want: []string{"one"},
+		},
+		{
+			name: "crlf line endings",
+			body: "data: one\r\n\r\ndata: two\r\n\r\n",
+			want: []string{"one", "two"},
+		},
+		{
+			name: "no space after colon",
+			body: "data:one\n\n",
+			want: []string{"one"},
+		},
+		{
+			name: "last event without blank line",
+			body: "data: one\n\ndata: two",
+			want: []string{"one", "two"},
+		},
+		{
+			name: "empty body",
+			body: "",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &utils.HttpResponse{Response: &http.Response{Body: io.NopCloser(strings.NewReader(tt.body))}}
+
+			var got []string
+			if err := readEventStream(res, func(data string) { got = append(got, data) }); err != nil {
+				t.Fatalf("readEventStream() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("readEventStream() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+const (
+	toolRole     = "tool"
+	functionType = "function"
+	// maxToolRounds bounds how often the model may call tools before it has
+	// to give a final answer.
+	maxToolRounds = 10
+)
+
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Tool is a Go function the model can call to fetch context on demand.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments object.
+	Parameters map[string]any
+	// Handler receives the raw JSON arguments and returns the result that
+	// is sent back to the model.
+	Handler func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+type ToolCall struct {
+	// Index identifies the call across the deltas of a streamed response.
+	Index    int              `json:"index,omitempty"`
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// RegisterTool implements Copilot.
+func (c *copilot) RegisterTool(tool Tool) error {
+	if !toolNamePattern.MatchString(tool.Name) {
+		return fmt.Errorf("invalid tool name '%s'", tool.Name)
+	}
+	if tool.Handler == nil {
+		return fmt.Errorf("tool '%s' has no handler", tool.Name)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.tools[tool.Name]; exists {
+		return fmt.Errorf("tool '%s' already registered", tool.Name)
+	}
+	if tool.Parameters == nil {
+		tool.Parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+
+	c.tools[tool.Name] = &tool
+	return nil
+}
+
+// toolDefinitions returns the registered tools in the format of the request
+// body, sorted by name to keep requests stable.
+func (c *copilot) toolDefinitions() []map[string]any {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.tools))
+	for name := range c.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	definitions := make([]map[string]any, 0, len(names))
+	for _, name := range names {
+		tool := c.tools[name]
+		definitions = append(definitions, map[string]any{
+			"type": functionType,
+			"function": map[string]any{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  tool.Parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// callTool runs the tool requested by the model and returns the message
+// carrying its result. Failures are reported to the model rather than
+// aborting the conversation, so it can correct its arguments.
+func (c *copilot) callTool(ctx context.Context, call ToolCall) PromptMessage {
+	message := PromptMessage{
+		Role:       toolRole,
+		ToolCallID: call.ID,
+	}
+
+	c.mu.RLock()
+	tool, ok := c.tools[call.Function.Name]
+	c.mu.RUnlock()
+	if !ok {
+		message.Content = fmt.Sprintf("error: unknown tool '%s'", call.Function.Name)
+		return message
+	}
+
+	args := json.RawMessage(call.Function.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+
+	result, err := tool.Handler(ctx, args)
+	if err != nil {
+		message.Content = "error: " + err.Error()
+		return message
+	}
+	message.Content = result
+	return message
+}
+
+// mergeToolCallDeltas accumulates the partial tool calls of a streamed
+// response into calls.
+func mergeToolCallDeltas(calls []ToolCall, deltas []ToolCall) []ToolCall {
+	for _, delta := range deltas {
+		for len(calls) <= delta.Index {
+			calls = append(calls, ToolCall{Type: functionType})
+		}
+		call := &calls[delta.Index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Type != "" {
+			call.Type = delta.Type
+		}
+		call.Function.Name += delta.Function.Name
+		call.Function.Arguments += delta.Function.Arguments
+	}
+	return calls
+}
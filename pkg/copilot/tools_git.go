@@ -0,0 +1,157 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mr687/lazycopilot/pkg/utils"
+)
+
+const (
+	defaultLogCount = 10
+	maxLogCount     = 50
+	// maxToolOutput keeps tool results from filling the context window.
+	maxToolOutput = 32 * 1024
+)
+
+// GitTools returns read-only tools that let the model inspect the history and
+// files of the repository at repoPath.
+func GitTools(repoPath string) []Tool {
+	return []Tool{
+		{
+			Name:        "git_log",
+			Description: "List recent commits of the repository, optionally limited to a file or directory.",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"max_count": map[string]any{"type": "integer", "description": fmt.Sprintf("Number of commits, at most %d", maxLogCount)},
+					"path":      map[string]any{"type": "string", "description": "Only list commits touching this path"},
+				},
+			},
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				var args struct {
+					MaxCount int    `json:"max_count"`
+					Path     string `json:"path"`
+				}
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+				if args.MaxCount <= 0 {
+					args.MaxCount = defaultLogCount
+				}
+				args.MaxCount = min(args.MaxCount, maxLogCount)
+
+				log, err := utils.GetLog(repoPath, args.MaxCount, args.Path)
+				if err != nil {
+					return "", err
+				}
+				return limitToolOutput(log), nil
+			},
+		},
+		{
+			Name:        "git_show",
+			Description: "Show the content of a file at a git revision.",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"file":     map[string]any{"type": "string", "description": "Path of the file relative to the repository root"},
+					"revision": map[string]any{"type": "string", "description": "Revision to read the file from, defaults to HEAD"},
+				},
+				"required": []string{"file"},
+			},
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				var args struct {
+					File     string `json:"file"`
+					Revision string `json:"revision"`
+				}
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+				if args.Revision == "" {
+					args.Revision = "HEAD"
+				}
+
+				content, err := utils.ShowFile(repoPath, args.Revision, args.File)
+				if err != nil {
+					return "", err
+				}
+				return limitToolOutput(content), nil
+			},
+		},
+		{
+			Name:        "read_file",
+			Description: "Read a file of the working tree, including uncommitted changes.",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{"type": "string", "description": "Path of the file relative to the repository root"},
+				},
+				"required": []string{"path"},
+			},
+			Handler: func(ctx context.Context, raw json.RawMessage) (string, error) {
+				var args struct {
+					Path string `json:"path"`
+				}
+				if err := json.Unmarshal(raw, &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+
+				path, err := resolveRepoPath(repoPath, args.Path)
+				if err != nil {
+					return "", err
+				}
+				content, err := os.ReadFile(path)
+				if err != nil {
+					return "", err
+				}
+				return limitToolOutput(string(content)), nil
+			},
+		},
+	}
+}
+
+// resolveRepoPath joins file to the repository root and refuses paths that
+// point outside of it, also by following symlinks.
+func resolveRepoPath(repoPath, file string) (string, error) {
+	root, err := filepath.Abs(repoPath)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(root, file)
+	if !isInside(root, path) {
+		return "", fmt.Errorf("path '%s' is outside of the repository", file)
+	}
+
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", err
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return "", err
+	}
+	if !isInside(root, path) {
+		return "", fmt.Errorf("path '%s' is outside of the repository", file)
+	}
+	return path, nil
+}
+
+func isInside(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func limitToolOutput(output string) string {
+	if len(output) <= maxToolOutput {
+		return output
+	}
+	// Cut before a character rather than through it
+	end := maxToolOutput
+	for end > 0 && !utf8.RuneStart(output[end]) {
+		end--
+	}
+	return output[:end] + "\n[output truncated]"
+}
@@ -0,0 +1,70 @@
+package tokenizer
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+const (
+	CL100K = "cl100k_base"
+	O200K  = "o200k_base"
+)
+
+// DefaultEncoding is used for models that don't report a tokenizer.
+const DefaultEncoding = CL100K
+
+func init() {
+	// Use the embedded BPE ranks instead of downloading them on first use
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+var (
+	mu        sync.Mutex
+	encodings = make(map[string]*tiktoken.Tiktoken)
+)
+
+type Tokenizer struct {
+	encoding *tiktoken.Tiktoken
+}
+
+// New returns a tokenizer for the given encoding name, as reported by
+// ModelCapabilities.Tokenizer. Encodings are loaded once and shared.
+func New(encoding string) (*Tokenizer, error) {
+	if encoding == "" {
+		encoding = DefaultEncoding
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	enc, ok := encodings[encoding]
+	if !ok {
+		var err error
+		enc, err = tiktoken.GetEncoding(encoding)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported tokenizer %s: %w", encoding, err)
+		}
+		encodings[encoding] = enc
+	}
+
+	return &Tokenizer{encoding: enc}, nil
+}
+
+func (t *Tokenizer) Count(text string) int {
+	return len(t.encoding.Encode(text, nil, nil))
+}
+
+// Truncate cuts text down to at most maxTokens tokens.
+func (t *Tokenizer) Truncate(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	tokens := t.encoding.Encode(text, nil, nil)
+	if len(tokens) <= maxTokens {
+		return text
+	}
+	return t.encoding.Decode(tokens[:maxTokens])
+}
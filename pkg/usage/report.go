@@ -0,0 +1,76 @@
+package usage
+
+import (
+	"sort"
+	"time"
+)
+
+// Totals sums up the usage of a group of records.
+type Totals struct {
+	Key              string `json:"key,omitempty"`
+	Requests         int    `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	CachedTokens     int64  `json:"cached_tokens"`
+	ReasoningTokens  int64  `json:"reasoning_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+}
+
+type Report struct {
+	Since     time.Time `json:"since"`
+	Total     Totals    `json:"total"`
+	ByDay     []Totals  `json:"by_day"`
+	ByModel   []Totals  `json:"by_model"`
+	ByCommand []Totals  `json:"by_command"`
+}
+
+func (t *Totals) add(record Record) {
+	t.Requests++
+	t.PromptTokens += record.PromptTokens
+	t.CompletionTokens += record.CompletionTokens
+	t.CachedTokens += record.CachedTokens
+	t.ReasoningTokens += record.ReasoningTokens
+	t.TotalTokens += record.TotalTokens
+}
+
+// NewReport groups the records by local day, model and command.
+func NewReport(records []Record, since time.Time) Report {
+	report := Report{Since: since}
+	for _, record := range records {
+		report.Total.add(record)
+	}
+
+	report.ByDay = groupBy(records, func(r Record) string { return r.Time.Local().Format(time.DateOnly) })
+	report.ByModel = groupBy(records, func(r Record) string { return r.Model })
+	report.ByCommand = groupBy(records, func(r Record) string { return r.Command })
+
+	// Days read best in chronological order, the rest by consumption
+	sort.Slice(report.ByDay, func(i, j int) bool { return report.ByDay[i].Key < report.ByDay[j].Key })
+	return report
+}
+
+func groupBy(records []Record, key func(Record) string) []Totals {
+	groups := make(map[string]*Totals)
+	for _, record := range records {
+		k := key(record)
+		if k == "" {
+			k = "unknown"
+		}
+		if _, ok := groups[k]; !ok {
+			groups[k] = &Totals{Key: k}
+		}
+		groups[k].add(record)
+	}
+
+	totals := make([]Totals, 0, len(groups))
+	for _, t := range groups {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].TotalTokens != totals[j].TotalTokens {
+			return totals[i].TotalTokens > totals[j].TotalTokens
+		}
+		return totals[i].Key < totals[j].Key
+	})
+	return totals
+}
@@ -0,0 +1,104 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mr687/lazycopilot/pkg/utils"
+)
+
+const ledgerFileName = "usage.jsonl"
+
+// Record is the token usage of a single request.
+type Record struct {
+	Time             time.Time `json:"time"`
+	Model            string    `json:"model"`
+	Command          string    `json:"command"`
+	Repo             string    `json:"repo,omitempty"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	CachedTokens     int64     `json:"cached_tokens"`
+	ReasoningTokens  int64     `json:"reasoning_tokens"`
+	TotalTokens      int64     `json:"total_tokens"`
+}
+
+var (
+	mu      sync.Mutex
+	command string
+	repo    string
+)
+
+// SetContext sets the command and repository stored with every record made
+// by this process.
+func SetContext(cmd, repository string) {
+	mu.Lock()
+	defer mu.Unlock()
+	command = cmd
+	repo = repository
+}
+
+func GetLedgerPath() string {
+	return filepath.Join(utils.GetConfigPath(), "lazycopilot", ledgerFileName)
+}
+
+// Append adds a record to the ledger, filling in the time and the context of
+// the process when they are not set.
+func Append(record Record) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	if record.Command == "" {
+		record.Command = command
+	}
+	if record.Repo == "" {
+		record.Repo = repo
+	}
+
+	path := GetLedgerPath()
+	if err := utils.Mkdir(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o664)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(utils.MustJsonBytes(record), '\n'))
+	return err
+}
+
+// Load returns the records made at or after since.
+func Load(since time.Time) ([]Record, error) {
+	f, err := os.Open(GetLedgerPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Record{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	records := make([]Record, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// Skip lines cut short by an interrupted write
+			continue
+		}
+		if record.Time.Before(since) {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
@@ -1,11 +1,18 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
-func GetDiff(path string, staged bool) string {
+// GetDiff returns the changes of the working tree, or the staged ones. Any
+// revisions, e.g. "main...feature", are passed on to compare commits
+// instead.
+func GetDiff(path string, staged bool, revisions ...string) string {
 	if path == "" {
 		path = "$(pwd)"
 	}
@@ -19,6 +26,7 @@ func GetDiff(path string, staged bool) string {
 		args = append(args, "--staged")
 	}
 	args = append(args, "--no-color", "--no-ext-diff")
+	args = append(args, revisions...)
 
 	cmd := exec.Command(args[0], args[1:]...)
 	out, err := cmd.Output()
@@ -45,3 +53,439 @@ func StageChanges(path string) error {
 	err := cmd.Run()
 	return err
 }
+
+// GetLog returns the last maxCount commits, one per line, optionally limited
+// to those touching file.
+func GetLog(path string, maxCount int, file string) (string, error) {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"log",
+		"--oneline",
+		"--no-color",
+		"-n",
+		strconv.Itoa(maxCount),
+	}
+	if file != "" {
+		args = append(args, "--", file)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ShowFile returns the content of file at the given revision. The revision
+// is resolved first so that it can't be taken for an option of git show.
+func ShowFile(path, revision, file string) (string, error) {
+	commit, err := ResolveRevision(path, revision)
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"show",
+		"--no-color",
+		"--end-of-options",
+		commit + ":" + file,
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return string(out), nil
+}
+
+// gitError includes the stderr of a failed git command in the error.
+func gitError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
+
+// GetRepoRoot returns the top level directory of the repository containing
+// path.
+func GetRepoRoot(path string) (string, error) {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"rev-parse",
+		"--show-toplevel",
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GetHooksPath returns the directory git runs the hooks of the repository
+// containing path from, honoring core.hooksPath.
+func GetHooksPath(path string) (string, error) {
+	return GetGitPath(path, "hooks")
+}
+
+// GetGitPath returns the absolute path of a file in the git directory of
+// the repository containing path, e.g. MERGE_MSG.
+func GetGitPath(path, name string) (string, error) {
+	root, err := GetRepoRoot(path)
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{
+		"git",
+		"-C",
+		root,
+		"rev-parse",
+		"--git-path",
+		name,
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+
+	// Relative paths, including a relative core.hooksPath, are relative to
+	// the top level directory
+	gitPath := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(gitPath) {
+		gitPath = filepath.Join(root, gitPath)
+	}
+	return gitPath, nil
+}
+
+// ListCommits returns the commits of revRange, oldest first. Extra args are
+// passed to git rev-list, e.g. to select merges only.
+func ListCommits(path string, revRange string, extraArgs ...string) ([]string, error) {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"rev-list",
+		"--reverse",
+	}
+	args = append(args, extraArgs...)
+	args = append(args, revRange, "--")
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// ResolveRevision returns the full hash of the commit revision points to.
+// Revisions that look like options are refused.
+func ResolveRevision(path, revision string) (string, error) {
+	if strings.HasPrefix(revision, "-") {
+		return "", fmt.Errorf("invalid revision '%s'", revision)
+	}
+
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"rev-parse",
+		"--verify",
+		"--quiet",
+		"--end-of-options",
+		revision + "^{commit}",
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("unknown revision '%s'", revision)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GetCommitDiff returns the changes a commit introduced.
+func GetCommitDiff(path, revision string) (string, error) {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"show",
+		"--format=",
+		"--no-color",
+		"--no-ext-diff",
+		revision,
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GetCommitMessage returns the full message of a commit.
+func GetCommitMessage(path, revision string) (string, error) {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"log",
+		"-1",
+		"--format=%B",
+		revision,
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GetCommentChar returns core.commentChar, or nothing when it isn't set.
+func GetCommentChar(path string) string {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"config",
+		"core.commentChar",
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// ParseTrailers returns the trailers of a commit message, such as
+// Signed-off-by, as git interpret-trailers finds them.
+func ParseTrailers(path, message string) ([]string, error) {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"interpret-trailers",
+		"--parse",
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(message + "\n")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+
+	trailers := make([]string, 0)
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			trailers = append(trailers, line)
+		}
+	}
+	return trailers, nil
+}
+
+// GetUpstream returns the upstream branch of the current branch, or nothing
+// when it has none.
+func GetUpstream(path string) string {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"rev-parse",
+		"--abbrev-ref",
+		"--symbolic-full-name",
+		"@{upstream}",
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// IsAncestor reports whether commit is reachable from revision.
+func IsAncestor(path, commit, revision string) (bool, error) {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"merge-base",
+		"--is-ancestor",
+		commit,
+		revision,
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, gitError(err)
+	}
+	return true, nil
+}
+
+// HasUncommittedChanges reports whether tracked files have staged or
+// unstaged changes.
+func HasUncommittedChanges(path string) (bool, error) {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"status",
+		"--porcelain",
+		"--untracked-files=no",
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return false, gitError(err)
+	}
+	return strings.TrimSpace(string(out)) != "", nil
+}
+
+// GetDiffStat returns the summary of changed files and lines between
+// revisions, e.g. "main...feature".
+func GetDiffStat(path string, revisions ...string) (string, error) {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"diff",
+		"--stat",
+		"--no-color",
+	}
+	args = append(args, revisions...)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// GetRemoteURL returns the URL of a remote, or nothing when it isn't set.
+func GetRemoteURL(path, remote string) string {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"remote",
+		"get-url",
+		remote,
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// GetCommitDate returns the committer date of a commit as YYYY-MM-DD.
+func GetCommitDate(path, revision string) (string, error) {
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"log",
+		"-1",
+		"--format=%cs",
+		revision,
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", gitError(err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IsTag reports whether name is a tag.
+func IsTag(path, name string) bool {
+	_, err := ResolveRevision(path, "refs/tags/"+name)
+	return err == nil
+}
+
+// CheckBranchName reports why name isn't a valid branch name, as decided by
+// git check-ref-format. Names that git would read as an option or expand,
+// like @{-1}, are rejected before.
+func CheckBranchName(path, name string) error {
+	if strings.HasPrefix(name, "-") || strings.Contains(name, "@{") || name == "HEAD" {
+		return fmt.Errorf("'%s' is not a valid branch name", name)
+	}
+
+	// check-ref-format takes no --end-of-options, the full ref can't be
+	// mistaken for an option
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"check-ref-format",
+		"refs/heads/" + name,
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	if _, err := cmd.Output(); err != nil {
+		return fmt.Errorf("'%s' is not a valid branch name", name)
+	}
+	return nil
+}
+
+// BranchExists reports whether a local branch exists.
+func BranchExists(path, name string) bool {
+	_, err := ResolveRevision(path, "refs/heads/"+name)
+	return err == nil
+}
+
+// SwitchNewBranch creates a branch at HEAD and switches to it, carrying the
+// uncommitted changes over.
+func SwitchNewBranch(path, name string) error {
+	if err := CheckBranchName(path, name); err != nil {
+		return err
+	}
+
+	// switch takes --end-of-options as the value of --create, the name is
+	// attached instead
+	args := []string{
+		"git",
+		"-C",
+		path,
+		"switch",
+		"--create=" + name,
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	if _, err := cmd.Output(); err != nil {
+		return gitError(err)
+	}
+	return nil
+}
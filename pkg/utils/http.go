@@ -5,7 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"math/rand"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 var VERSION_HEADERS = map[string]string{
@@ -20,50 +23,151 @@ var VERSION_HEADERS = map[string]string{
 
 type Headers map[string]string
 
+// RetryPolicy controls how HttpRequest retries rate limited (429) and failed
+// (5xx) responses as well as network errors.
+type RetryPolicy struct {
+	MaxRetries int
+	// BaseDelay is doubled on every attempt up to MaxDelay, and the delay is
+	// then jittered between its half and its whole.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxRetryAfter is the longest Retry-After the request waits for, longer
+	// ones return the response to the caller right away.
+	MaxRetryAfter time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:    3,
+	BaseDelay:     500 * time.Millisecond,
+	MaxDelay:      10 * time.Second,
+	MaxRetryAfter: time.Minute,
+}
+
 type HttpOptions struct {
 	Url     string
 	Method  string
 	Headers *Headers
 	Body    interface{}
+	// Retry defaults to DefaultRetryPolicy.
+	Retry *RetryPolicy
 }
 
 type HttpResponse struct {
 	*http.Response
 }
 
+var httpClient = &http.Client{}
+
 func HttpRequest(ctx context.Context, opts HttpOptions) (*HttpResponse, error) {
-	var body io.Reader
+	var data []byte
 	if opts.Body != nil {
-		data, err := json.Marshal(opts.Body)
+		var err error
+		data, err = json.Marshal(opts.Body)
 		if err != nil {
 			return nil, err
 		}
-		body = bytes.NewReader(data)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, opts.Method, opts.Url, body)
-	if err != nil {
-		return nil, err
+	policy := DefaultRetryPolicy
+	if opts.Retry != nil {
+		policy = *opts.Retry
 	}
 
-	if opts.Headers != nil {
-		for key, value := range *opts.Headers {
-			req.Header.Set(key, value)
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if data != nil {
+			body = bytes.NewReader(data)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, opts.Method, opts.Url, body)
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.Headers != nil {
+			for key, value := range *opts.Headers {
+				req.Header.Set(key, value)
+			}
+		}
+
+		res, err := httpClient.Do(req)
+		if err != nil {
+			// Network errors are retried unless the caller gave up
+			if ctx.Err() != nil || attempt >= policy.MaxRetries {
+				return nil, err
+			}
+			if err := sleep(ctx, policy.backoff(attempt)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !isRetryableStatus(res.StatusCode) || attempt >= policy.MaxRetries {
+			return &HttpResponse{res}, nil
+		}
+
+		delay := policy.backoff(attempt)
+		if retryAfter, ok := RetryAfter(res); ok {
+			if retryAfter > policy.MaxRetryAfter {
+				return &HttpResponse{res}, nil
+			}
+			delay = retryAfter
+		}
+		res.Body.Close()
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
 		}
 	}
-	for key, value := range VERSION_HEADERS {
-		req.Header.Set(key, value)
-	}
+}
 
-	client := &http.Client{}
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// backoff returns the jittered exponential delay before the given retry.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << attempt
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
 	}
+	// Equal jitter: half of the delay is kept, the other half is random
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
 
-	httpResp := &HttpResponse{res}
+// RetryAfter parses the Retry-After header, given either in seconds or as an
+// HTTP date.
+func RetryAfter(res *http.Response) (time.Duration, bool) {
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
 
-	return httpResp, nil
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 func (r *HttpResponse) StringDecode() (string, error) {
@@ -80,6 +80,43 @@ func SaveFile(p string, data interface{}) error {
 	return os.WriteFile(p, bin, 0o664)
 }
 
+// SaveFileAtomic works like SaveFile but writes to a temporary file that is
+// renamed over p, so readers never see a partially written file.
+func SaveFileAtomic(p string, data interface{}) error {
+	dir := filepath.Dir(p)
+	_ = Mkdir(dir)
+
+	var bin []byte
+	switch t := data.(type) {
+	case []byte:
+		bin = t
+	case string:
+		bin = []byte(t)
+	default:
+		bin = MustJsonBytes(data)
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(p)+".*")
+	if err != nil {
+		return err
+	}
+	// Fails harmlessly once the file was renamed
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(bin); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
 func SetCurrentOSName() string {
 	os := runtime.GOOS
 	switch os {